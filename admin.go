@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goCrawlerHot/auth"
+	"goCrawlerHot/config"
+	"goCrawlerHot/cralwer"
+	"goCrawlerHot/i18n"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxAdminFailures 管理页面显示的最近失败记录数
+const maxAdminFailures = 50
+
+// sourceSettings 管理页面可以修改的数据源配置, 请求中未出现的字段保持不变
+type sourceSettings struct {
+	Disabled  *bool             `json:"disabled,omitempty"`
+	URL       *string           `json:"url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Interval  *int              `json:"interval,omitempty"`
+	Selectors map[string]string `json:"selectors,omitempty"`
+}
+
+// editable 返回数据源配置中可以在管理页面修改的部分, cookie 和代理等不会返回
+func editable(source config.Source) sourceSettings {
+	return sourceSettings{
+		Disabled:  &source.Disabled,
+		URL:       &source.URL,
+		Headers:   source.Headers,
+		Interval:  &source.Interval,
+		Selectors: source.Selectors,
+	}
+}
+
+// apply 把修改合并到数据源配置, 空的请求头和选择器表示恢复内置值
+func (s sourceSettings) apply(source config.Source) config.Source {
+	if s.Disabled != nil {
+		source.Disabled = *s.Disabled
+	}
+	if s.URL != nil {
+		source.URL = strings.TrimSpace(*s.URL)
+	}
+	if s.Interval != nil {
+		source.Interval = *s.Interval
+	}
+	if s.Headers != nil {
+		source.Headers = nonEmpty(s.Headers)
+	}
+	if s.Selectors != nil {
+		source.Selectors = nonEmpty(s.Selectors)
+	}
+	return source
+}
+
+// nonEmpty 去掉值为空的项, 全部为空时返回 nil, 写回配置文件时省略该字段
+func nonEmpty(values map[string]string) map[string]string {
+	var result map[string]string
+	for key, value := range values {
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if key == "" || value == "" {
+			continue
+		}
+		if result == nil {
+			result = map[string]string{}
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// adminSource 管理页面的一个数据源
+type adminSource struct {
+	cralwer.SourceInfo
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// Interval 生效的抓取间隔分钟数
+	Interval    int            `json:"interval"`
+	Settings    sourceSettings `json:"settings"`
+	CrawlerTime string         `json:"crawler_time,omitempty"`
+	Count       int            `json:"count"`
+	// Filtered 最近一次抓取被过滤掉的条数
+	Filtered int `json:"filtered"`
+	// Failures 最近失败记录中该数据源的次数, LastError 为其中最新的一条
+	Failures  int              `json:"failures"`
+	LastError *cralwer.Failure `json:"last_error,omitempty"`
+}
+
+// adminPage 管理页面的数据
+type adminPage struct {
+	Sources []adminSource
+	// Failures 最近的失败记录, 最新的在前
+	Failures []cralwer.Failure
+	// Filtered 最近被过滤的条目, 最新的在前
+	Filtered []cralwer.FilteredItem
+	User     pageUser
+}
+
+func adminSources(lang string) []adminSource {
+	results, err := cralwer.ReadAllResults()
+	if err != nil {
+		fmt.Println("read results err:", err)
+	}
+	latest := map[string]cralwer.Result{}
+	for _, result := range results {
+		latest[result.Source] = result
+	}
+	failures := cralwer.RecentFailures()
+	var sources []adminSource
+	for _, info := range cralwer.SourceInfos() {
+		item := adminSource{
+			SourceInfo:  info,
+			Name:        cralwer.SourceName(info.ID, lang),
+			Enabled:     cralwer.Enabled(info.ID),
+			Interval:    int(cralwer.Interval(info.ID).Minutes()),
+			Settings:    editable(config.SourceOf(info.ID)),
+			CrawlerTime: latest[info.ID].CrawlerTime,
+			Count:       len(latest[info.ID].Content),
+			Filtered:    latest[info.ID].Filtered,
+		}
+		for i := range failures {
+			if failures[i].Source == info.ID {
+				item.Failures++
+				item.LastError = &failures[i]
+			}
+		}
+		sources = append(sources, item)
+	}
+	return sources
+}
+
+// recentFailures 返回最近的失败记录, 最新的在前, source 不为空时只返回该数据源的记录
+func recentFailures(source string, limit int) []cralwer.Failure {
+	all := cralwer.RecentFailures()
+	var failures []cralwer.Failure
+	for i := len(all) - 1; i >= 0 && len(failures) < limit; i-- {
+		if source == "" || all[i].Source == source {
+			failures = append(failures, all[i])
+		}
+	}
+	return failures
+}
+
+// recentFiltered 返回最近被过滤的条目, 最新的在前, source 不为空时只返回该数据源的条目
+func recentFiltered(source string, limit int) []cralwer.FilteredItem {
+	all := cralwer.RecentFiltered()
+	var items []cralwer.FilteredItem
+	for i := len(all) - 1; i >= 0 && len(items) < limit; i-- {
+		if source == "" || all[i].Source == source {
+			items = append(items, all[i])
+		}
+	}
+	return items
+}
+
+// adminHandler 数据源管理页面, GET /admin
+func adminHandler(writer http.ResponseWriter, request *http.Request) {
+	lang := i18n.Negotiate(writer, request)
+	renderPage(writer, lang, "admin.html", adminPage{
+		Sources:  adminSources(lang),
+		Failures: recentFailures("", maxAdminFailures),
+		Filtered: recentFiltered("", maxAdminFailures),
+		User:     currentUser(request),
+	})
+}
+
+// readSettings 读取请求体中的数据源配置修改, 请求体为空时返回零值
+func readSettings(writer http.ResponseWriter, request *http.Request) (sourceSettings, error) {
+	var settings sourceSettings
+	err := json.NewDecoder(http.MaxBytesReader(writer, request.Body, 1<<20)).Decode(&settings)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// adminSourcesHandler 数据源配置, GET /api/admin/sources 返回全部数据源的配置和状态,
+// POST /api/admin/sources?source=CrawlerZhiHu 修改配置, 请求体为 {"disabled", "url", "headers", "interval", "selectors"} 中要修改的字段,
+// 修改写回配置文件后立即生效, 集群模式下保存到共享存储, 其它实例在下一次同步时生效
+func adminSourcesHandler(writer http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		writeJSON(writer, http.StatusOK, map[string]interface{}{"sources": adminSources(i18n.Negotiate(writer, request))})
+	case http.MethodPost:
+		name := request.URL.Query().Get("source")
+		if !cralwer.IsSource(name) {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("unknown source %q", name)})
+			return
+		}
+		settings, err := readSettings(writer, request)
+		if err != nil {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid body"})
+			return
+		}
+		source := settings.apply(config.SourceOf(name))
+		if err := cralwer.CheckSettings(name, source); err != nil {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := cralwer.SaveSource(name, source); err != nil {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		user := "anonymous"
+		if p := auth.FromRequest(request); p != nil {
+			user = p.Name
+		}
+		fmt.Println("数据源配置已修改:", name, "by", user)
+		writeJSON(writer, http.StatusOK, map[string]interface{}{"source": name, "settings": editable(source)})
+	default:
+		writer.Header().Set("Allow", "GET, POST")
+		writeJSON(writer, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// adminTestHandler 测试抓取, POST /api/admin/test?source=CrawlerZhiHu, 请求体与修改配置相同, 用于测试尚未保存的修改,
+// 返回解析出的条目和原始响应, 结果不写入存储
+func adminTestHandler(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writer.Header().Set("Allow", http.MethodPost)
+		writeJSON(writer, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	name := request.URL.Query().Get("source")
+	settings, err := readSettings(writer, request)
+	if err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid body"})
+		return
+	}
+	draft := settings.apply(config.SourceOf(name))
+	if err := cralwer.CheckSettings(name, draft); err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	probe, err := cralwer.TestCrawl(name, &draft)
+	if err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(writer, http.StatusOK, probe)
+}
+
+// adminFailuresHandler 最近的抓取失败, GET /api/admin/failures[?source=CrawlerZhiHu], 最新的在前
+func adminFailuresHandler(writer http.ResponseWriter, request *http.Request) {
+	writeJSON(writer, http.StatusOK, map[string]interface{}{
+		"failures": recentFailures(request.URL.Query().Get("source"), maxAdminFailures),
+	})
+}
+
+// adminFilteredHandler 最近被过滤的条目及原因, GET /api/admin/filtered[?source=CrawlerZhiHu], 最新的在前
+func adminFilteredHandler(writer http.ResponseWriter, request *http.Request) {
+	writeJSON(writer, http.StatusOK, map[string]interface{}{
+		"filtered": recentFiltered(request.URL.Query().Get("source"), maxAdminFailures),
+	})
+}
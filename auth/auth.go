@@ -0,0 +1,238 @@
+// Package auth 页面和接口的认证与角色, 支持静态 API 令牌、basic 认证和 OpenID Connect
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"goCrawlerHot/config"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role 角色, 较高的角色拥有较低角色的全部权限
+type Role int
+
+const (
+	// None 没有任何权限, 用于 anonymous_role 为 none 时的未登录请求
+	None Role = iota
+	// Viewer 查看页面和只读接口, 管理自己的已读和收藏
+	Viewer
+	// Editor 触发抓取等会修改热榜数据的操作
+	Editor
+	// Admin 管理数据源和配置
+	Admin
+)
+
+var roleNames = []string{"none", "viewer", "editor", "admin"}
+
+func (r Role) String() string {
+	if r < None || r > Admin {
+		return fmt.Sprintf("Role(%d)", int(r))
+	}
+	return roleNames[r]
+}
+
+func (r Role) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+// ParseRole 解析配置中的角色名称
+func ParseRole(name string) (Role, error) {
+	for i, roleName := range roleNames {
+		if name == roleName {
+			return Role(i), nil
+		}
+	}
+	return None, fmt.Errorf("unknown role %q", name)
+}
+
+// Principal 通过认证的用户
+type Principal struct {
+	// ID 稳定的用户标识, 以认证方式开头, 如 basic:alice、token:ci、oidc:<sub>, 用于区分用户的数据
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Role Role   `json:"role"`
+	// Method 认证方式: token、basic、oidc 或 session
+	Method string `json:"method"`
+}
+
+// Authenticator 一种认证方式
+type Authenticator interface {
+	// Authenticate 请求没有携带这种方式的凭据时返回 nil, nil; 携带了无效的凭据时返回错误
+	Authenticate(request *http.Request) (*Principal, error)
+}
+
+// settings Setup 之后的认证配置
+type settings struct {
+	anonymous      Role
+	authenticators []Authenticator
+	basic          bool
+	oidc           *provider
+	sessions       *sessionCodec
+}
+
+var (
+	current   = &settings{anonymous: Viewer}
+	currentMu sync.RWMutex
+)
+
+func get() *settings {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// Setup 按配置创建认证方式, config.admin_token 视为一个 admin 角色的令牌
+func Setup(cfg config.Config) error {
+	auth := cfg.Auth
+	s := &settings{anonymous: None}
+	if auth.AnonymousRole != "none" {
+		role, err := ParseRole(auth.AnonymousRole)
+		if err != nil {
+			return fmt.Errorf("auth.anonymous_role: %w", err)
+		}
+		s.anonymous = role
+	}
+
+	secret := []byte(auth.SessionSecret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			return err
+		}
+	}
+	s.sessions = &sessionCodec{secret: secret, ttl: time.Duration(auth.SessionTTL) * time.Minute}
+	s.authenticators = append(s.authenticators, s.sessions)
+
+	tokens := append([]config.APIToken(nil), auth.Tokens...)
+	if cfg.AdminToken != "" {
+		tokens = append(tokens, config.APIToken{Name: "admin_token", Token: cfg.AdminToken, Role: "admin"})
+	}
+	if len(tokens) > 0 {
+		ta, err := newTokenAuth(tokens)
+		if err != nil {
+			return err
+		}
+		ta.jwt = auth.OIDC.Issuer != ""
+		s.authenticators = append(s.authenticators, ta)
+	}
+	if len(auth.Users) > 0 {
+		ba, err := newBasicAuth(auth.Users)
+		if err != nil {
+			return err
+		}
+		s.authenticators = append(s.authenticators, ba)
+		s.basic = true
+	}
+	if auth.OIDC.Issuer != "" {
+		p, err := newProvider(auth.OIDC)
+		if err != nil {
+			return err
+		}
+		s.authenticators = append(s.authenticators, p)
+		s.oidc = p
+	}
+
+	currentMu.Lock()
+	current = s
+	currentMu.Unlock()
+	return nil
+}
+
+// authenticate 依次尝试各认证方式, 返回第一个认出的用户
+func (s *settings) authenticate(request *http.Request) (*Principal, error) {
+	for _, a := range s.authenticators {
+		p, err := a.Authenticate(request)
+		if err != nil || p != nil {
+			return p, err
+		}
+	}
+	return nil, nil
+}
+
+type principalKey struct{}
+
+// FromRequest 返回 Require 认出的用户, 未登录时返回 nil
+func FromRequest(request *http.Request) *Principal {
+	p, _ := request.Context().Value(principalKey{}).(*Principal)
+	return p
+}
+
+// Identify 返回请求的用户和角色, 未登录时用户为 nil, 角色为 anonymous_role
+func Identify(request *http.Request) (*Principal, Role, error) {
+	s := get()
+	p, err := s.authenticate(request)
+	if err != nil || p == nil {
+		return nil, s.anonymous, err
+	}
+	return p, p.Role, nil
+}
+
+// LoginEnabled 是否配置了 OIDC 登录
+func LoginEnabled() bool {
+	return get().oidc != nil
+}
+
+// Require 要求请求至少具有 role, 认证失败时接口返回 401/403 JSON, 页面跳转到登录或要求 basic 认证
+func Require(role Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		p, have, err := Identify(request)
+		if err != nil {
+			fmt.Println("auth err:", err)
+			deny(writer, request, http.StatusUnauthorized, "invalid credentials", false)
+			return
+		}
+		if have < role {
+			if p == nil {
+				deny(writer, request, http.StatusUnauthorized, "unauthorized", true)
+			} else {
+				deny(writer, request, http.StatusForbidden, "forbidden", false)
+			}
+			return
+		}
+		if p != nil {
+			request = request.WithContext(context.WithValue(request.Context(), principalKey{}, p))
+		}
+		next(writer, request)
+	}
+}
+
+// deny 拒绝请求, login 为 true 且配置了 OIDC 时页面请求跳转到登录页
+func deny(writer http.ResponseWriter, request *http.Request, status int, message string, login bool) {
+	s := get()
+	api := strings.HasPrefix(request.URL.Path, "/api/") || strings.HasPrefix(request.URL.Path, "/auth/")
+	if login && !api && s.oidc != nil && request.Method == http.MethodGet {
+		http.Redirect(writer, request, "/auth/login?next="+url.QueryEscape(request.URL.RequestURI()), http.StatusFound)
+		return
+	}
+	if status == http.StatusUnauthorized {
+		writer.Header().Add("WWW-Authenticate", `Bearer realm="goCrawlerHot"`)
+		if s.basic {
+			writer.Header().Add("WWW-Authenticate", `Basic realm="goCrawlerHot", charset="UTF-8"`)
+		}
+	}
+	if !api {
+		http.Error(writer, message, status)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.WriteHeader(status)
+	_ = json.NewEncoder(writer).Encode(map[string]string{"error": message})
+}
+
+// MeHandler 当前用户, GET /auth/me, 未登录时 user 为 null
+func MeHandler(writer http.ResponseWriter, request *http.Request) {
+	p, role, err := Identify(request)
+	if err != nil {
+		deny(writer, request, http.StatusUnauthorized, "invalid credentials", false)
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(writer).Encode(map[string]interface{}{"user": p, "role": role, "login": LoginEnabled()})
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"encoding/json"
+	"goCrawlerHot/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// setupRoles 配置每个角色一个令牌和一个 basic 用户
+func setupRoles(t *testing.T, anonymous string) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Auth.AnonymousRole = anonymous
+	cfg.Auth.Tokens = []config.APIToken{
+		{Name: "viewer", Token: "viewer-token", Role: "viewer"},
+		{Name: "editor", Token: "editor-token", Role: "editor"},
+		{Name: "admin", Token: "admin-token", Role: "admin"},
+	}
+	cfg.Auth.Users = []config.BasicUser{{Username: "ops", Password: "pass", Role: "admin"}}
+	cfg.AdminToken = "legacy-token"
+	if err := Setup(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = Setup(config.Default())
+	})
+}
+
+// routes 与 main.go 中注册的角色一致
+var routes = []struct {
+	method string
+	path   string
+	role   Role
+}{
+	{http.MethodGet, "/api/hot", Viewer},
+	{http.MethodPost, "/api/refresh", Editor},
+	{http.MethodGet, "/admin", Admin},
+	{http.MethodGet, "/api/admin/sources", Admin},
+	{http.MethodPost, "/api/admin/sources?source=CrawlerZhiHu", Admin},
+	{http.MethodPost, "/api/admin/test?source=CrawlerZhiHu", Admin},
+	{http.MethodGet, "/api/admin/failures", Admin},
+}
+
+func serve(role Role, request *http.Request) (*httptest.ResponseRecorder, *Principal) {
+	var seen *Principal
+	handler := Require(role, func(writer http.ResponseWriter, request *http.Request) {
+		seen = FromRequest(request)
+		writer.WriteHeader(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	handler(rec, request)
+	return rec, seen
+}
+
+func TestRequireRoles(t *testing.T) {
+	setupRoles(t, "viewer")
+	credentials := []struct {
+		name string
+		set  func(*http.Request)
+		role Role
+	}{
+		{"anonymous", func(*http.Request) {}, Viewer},
+		{"viewer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer viewer-token") }, Viewer},
+		{"editor token", func(r *http.Request) { r.Header.Set("X-API-Token", "editor-token") }, Editor},
+		{"admin token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer admin-token") }, Admin},
+		{"admin_token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer legacy-token") }, Admin},
+		{"basic admin", func(r *http.Request) { r.SetBasicAuth("ops", "pass") }, Admin},
+	}
+	for _, c := range credentials {
+		for _, route := range routes {
+			t.Run(c.name+" "+route.method+" "+route.path, func(t *testing.T) {
+				request := httptest.NewRequest(route.method, route.path, nil)
+				c.set(request)
+				rec, _ := serve(route.role, request)
+				want := http.StatusOK
+				if c.role < route.role {
+					// 匿名请求没有凭据, 其它请求凭据有效但角色不够
+					want = http.StatusForbidden
+					if c.name == "anonymous" {
+						want = http.StatusUnauthorized
+					}
+				}
+				if rec.Code != want {
+					t.Errorf("status = %d, want %d", rec.Code, want)
+				}
+			})
+		}
+	}
+}
+
+func TestRequireInvalidCredentials(t *testing.T) {
+	setupRoles(t, "viewer")
+	for name, set := range map[string]func(*http.Request){
+		"unknown token":  func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") },
+		"wrong password": func(r *http.Request) { r.SetBasicAuth("ops", "wrong") },
+		"unknown user":   func(r *http.Request) { r.SetBasicAuth("nobody", "pass") },
+	} {
+		t.Run(name, func(t *testing.T) {
+			// 携带无效凭据时即使匿名角色足够也拒绝, 避免凭据写错时被当作匿名用户
+			request := httptest.NewRequest(http.MethodGet, "/api/hot", nil)
+			set(request)
+			rec, _ := serve(Viewer, request)
+			if rec.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d", rec.Code)
+			}
+			var body map[string]string
+			if err := json.NewDecoder(rec.Body).Decode(&body); err != nil || body["error"] == "" {
+				t.Errorf("body = %v, %v", body, err)
+			}
+		})
+	}
+}
+
+func TestRequirePrincipal(t *testing.T) {
+	setupRoles(t, "none")
+	request := httptest.NewRequest(http.MethodGet, "/api/hot", nil)
+	if rec, _ := serve(Viewer, request); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous with anonymous_role none: status = %d", rec.Code)
+	}
+	request.SetBasicAuth("ops", "pass")
+	rec, p := serve(Admin, request)
+	if rec.Code != http.StatusOK || p == nil || p.ID != "basic:ops" || p.Method != "basic" {
+		t.Errorf("basic principal = %d %+v", rec.Code, p)
+	}
+	request = httptest.NewRequest(http.MethodGet, "/api/hot", nil)
+	request.Header.Set("Authorization", "Bearer editor-token")
+	if _, p := serve(Viewer, request); p == nil || p.ID != "token:editor" {
+		t.Errorf("token principal = %+v", p)
+	}
+}
@@ -0,0 +1,472 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goCrawlerHot/config"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stateCookie 登录跳转期间保存 state、nonce 和登录后返回的地址
+const stateCookie = "hot_oidc"
+
+// clockSkew 校验过期时间时容许的时钟误差
+const clockSkew = time.Minute
+
+// jwksMinInterval 遇到未知的 kid 时重新获取公钥的最短间隔
+const jwksMinInterval = time.Minute
+
+// discovery <issuer>/.well-known/openid-configuration 中用到的字段
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// provider OIDC 签发方, 端点和公钥在第一次使用时获取并缓存
+type provider struct {
+	cfg         config.OIDC
+	client      *http.Client
+	defaultRole Role
+	users       map[string]Role
+
+	mu          sync.Mutex
+	meta        *discovery
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+func newProvider(cfg config.OIDC) (*provider, error) {
+	p := &provider{cfg: cfg, client: &http.Client{Timeout: 10 * time.Second}, users: map[string]Role{}}
+	var err error
+	p.defaultRole, err = ParseRole(cfg.DefaultRole)
+	if err != nil {
+		return nil, fmt.Errorf("auth.oidc.default_role: %w", err)
+	}
+	for user, name := range cfg.Users {
+		p.users[user], err = ParseRole(name)
+		if err != nil {
+			return nil, fmt.Errorf("auth.oidc.users.%s: %w", user, err)
+		}
+	}
+	return p, nil
+}
+
+func (p *provider) getJSON(rawURL string, v interface{}) error {
+	resp, err := p.client.Get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// discover 返回签发方的端点, 获取失败时下次重试
+func (p *provider) discover() (*discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+	var meta discovery
+	err := p.getJSON(strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", &meta)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("issuer mismatch: configured %q, discovered %q", p.cfg.Issuer, meta.Issuer)
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// jwk JWKS 中的一个公钥, 支持 RSA 和 P-256
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// key 返回 kid 对应的公钥, 找不到时重新获取 JWKS(签发方轮换了密钥)
+func (p *provider) key(kid string) (crypto.PublicKey, error) {
+	meta, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetched) < jwksMinInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	err = p.getJSON(meta.JWKSURI, &set)
+	if err != nil {
+		return nil, err
+	}
+	p.keysFetched = time.Now()
+	p.keys = map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		p.keys[k.Kid] = key
+	}
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// verify 校验 ID Token 的签名、签发方、受众和有效期, nonce 不为空时同时校验 nonce, 返回其中的声明
+func (p *provider) verify(raw, nonce string) (map[string]interface{}, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(data, &header) != nil {
+		return nil, errors.New("malformed token header")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	key, err := p.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if header.Alg != "RS256" {
+			return nil, fmt.Errorf("unexpected alg %q for RSA key", header.Alg)
+		}
+		err = rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig)
+	case *ecdsa.PublicKey:
+		if header.Alg != "ES256" || len(sig) != 64 {
+			return nil, fmt.Errorf("unexpected alg %q for EC key", header.Alg)
+		}
+		if !ecdsa.Verify(pub, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			err = errors.New("invalid signature")
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("verify token: %w", err)
+	}
+
+	var claims map[string]interface{}
+	data, err = base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(data, &claims) != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != strings.TrimSuffix(p.cfg.Issuer, "/") {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if !audienceContains(claims["aud"], p.cfg.ClientID) {
+		return nil, errors.New("token was not issued for this client")
+	}
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.Add(-clockSkew).Unix() > int64(exp) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Unix() < int64(nbf) {
+		return nil, errors.New("token not valid yet")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, errors.New("token has no sub")
+	}
+	if got, _ := claims["nonce"].(string); nonce != "" && !hmac.Equal([]byte(got), []byte(nonce)) {
+		return nil, errors.New("nonce mismatch")
+	}
+	return claims, nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []interface{}:
+		for _, item := range v {
+			if item == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// principal 从声明中取用户名和角色: 用户名依次取 email、preferred_username、sub,
+// 角色先按 users 配置, 再取 role_claim 中最高的角色, 都没有时使用 default_role
+func (p *provider) principal(claims map[string]interface{}) *Principal {
+	sub, _ := claims["sub"].(string)
+	name := sub
+	for _, field := range []string{"preferred_username", "email"} {
+		if value, _ := claims[field].(string); value != "" {
+			name = value
+		}
+	}
+	// 未验证的 email 可以由用户随意填写, 不能用来匹配角色
+	email, _ := claims["email"].(string)
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		email = ""
+	}
+	for _, id := range []string{email, sub} {
+		if role, ok := p.users[id]; ok && id != "" {
+			return &Principal{ID: "oidc:" + sub, Name: name, Role: role, Method: "oidc"}
+		}
+	}
+	role := None
+	var values []interface{}
+	switch v := claims[p.cfg.RoleClaim].(type) {
+	case string:
+		values = []interface{}{v}
+	case []interface{}:
+		values = v
+	}
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			if r, err := ParseRole(s); err == nil && r > role {
+				role = r
+			}
+		}
+	}
+	if role == None {
+		role = p.defaultRole
+	}
+	return &Principal{ID: "oidc:" + sub, Name: name, Role: role, Method: "oidc"}
+}
+
+// Authenticate 接受签发方签发给本客户端的 ID Token 作为 Bearer 令牌
+func (p *provider) Authenticate(request *http.Request) (*Principal, error) {
+	raw := bearerToken(request)
+	if raw == "" || strings.Count(raw, ".") != 2 {
+		return nil, nil
+	}
+	claims, err := p.verify(raw, "")
+	if err != nil {
+		return nil, err
+	}
+	return p.principal(claims), nil
+}
+
+// loginState 登录跳转期间保存在 cookie 中的数据
+type loginState struct {
+	State string `json:"state"`
+	Nonce string `json:"nonce"`
+	Next  string `json:"next"`
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// localPath 只允许登录后跳转到本站的路径
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// LoginHandler 跳转到签发方登录, GET /auth/login[?next=/]
+func LoginHandler(writer http.ResponseWriter, request *http.Request) {
+	s := get()
+	if s.oidc == nil {
+		http.NotFound(writer, request)
+		return
+	}
+	meta, err := s.oidc.discover()
+	if err != nil {
+		fmt.Println("oidc discover err:", err)
+		http.Error(writer, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+	state := loginState{State: randomString(), Nonce: randomString(), Next: localPath(request.URL.Query().Get("next"))}
+	data, _ := json.Marshal(state)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	http.SetCookie(writer, &http.Cookie{
+		Name:     stateCookie,
+		Value:    payload + "." + s.sessions.sign(payload),
+		Path:     "/auth/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   request.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	target, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		http.Error(writer, "invalid authorization endpoint", http.StatusBadGateway)
+		return
+	}
+	query := target.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", s.oidc.cfg.ClientID)
+	query.Set("redirect_uri", s.oidc.cfg.RedirectURL)
+	query.Set("scope", strings.Join(append([]string{"openid", "email", "profile"}, s.oidc.cfg.Scopes...), " "))
+	query.Set("state", state.State)
+	query.Set("nonce", state.Nonce)
+	target.RawQuery = query.Encode()
+	http.Redirect(writer, request, target.String(), http.StatusFound)
+}
+
+// CallbackHandler 签发方登录后的回调, GET /auth/callback?code=...&state=..., 用 code 换取 ID Token 后写入会话
+func CallbackHandler(writer http.ResponseWriter, request *http.Request) {
+	s := get()
+	if s.oidc == nil {
+		http.NotFound(writer, request)
+		return
+	}
+	query := request.URL.Query()
+	if query.Get("error") != "" {
+		http.Error(writer, "login failed: "+query.Get("error"), http.StatusUnauthorized)
+		return
+	}
+	var state loginState
+	cookie, err := request.Cookie(stateCookie)
+	if err == nil {
+		parts := strings.Split(cookie.Value, ".")
+		if len(parts) == 2 && hmac.Equal([]byte(parts[1]), []byte(s.sessions.sign(parts[0]))) {
+			data, _ := base64.RawURLEncoding.DecodeString(parts[0])
+			_ = json.Unmarshal(data, &state)
+		}
+	}
+	if state.State == "" || !hmac.Equal([]byte(state.State), []byte(query.Get("state"))) {
+		http.Error(writer, "invalid login state, please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(writer, &http.Cookie{Name: stateCookie, Value: "", Path: "/auth/", MaxAge: -1})
+
+	rawToken, err := s.oidc.exchange(query.Get("code"))
+	if err != nil {
+		fmt.Println("oidc exchange err:", err)
+		http.Error(writer, "login failed", http.StatusBadGateway)
+		return
+	}
+	claims, err := s.oidc.verify(rawToken, state.Nonce)
+	if err != nil {
+		fmt.Println("oidc verify err:", err)
+		http.Error(writer, "login failed", http.StatusUnauthorized)
+		return
+	}
+	s.sessions.issue(writer, request, s.oidc.principal(claims))
+	http.Redirect(writer, request, localPath(state.Next), http.StatusFound)
+}
+
+// exchange 用授权码换取 ID Token, 客户端凭据使用 client_secret_basic 方式传递
+func (p *provider) exchange(code string) (string, error) {
+	if code == "" {
+		return "", errors.New("missing code")
+	}
+	meta, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {p.cfg.RedirectURL},
+	}
+	req, err := http.NewRequest(http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		IDToken string `json:"id_token"`
+		Error   string `json:"error"`
+	}
+	err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+	if err != nil {
+		return "", fmt.Errorf("token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("token endpoint: %s %s", resp.Status, body.Error)
+	}
+	return body.IDToken, nil
+}
+
+// LogoutHandler 清除登录会话, GET /auth/logout
+func LogoutHandler(writer http.ResponseWriter, request *http.Request) {
+	clearSession(writer)
+	http.Redirect(writer, request, "/", http.StatusFound)
+}
@@ -0,0 +1,476 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"goCrawlerHot/config"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testClientID = "hot-client"
+
+// mockIssuer 本地的 OIDC 签发方, 提供 discovery、JWKS 和 token 端点
+type mockIssuer struct {
+	server *httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mu sync.Mutex
+	// claims 返回 token 端点签发的声明, 参数为授权请求中的 nonce
+	claims func(nonce string) map[string]interface{}
+	// nonce 授权请求中的 nonce, 模拟签发方把它写入 ID Token
+	nonce string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIssuer{rsaKey: rsaKey, ecKey: ecKey}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(discovery{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			JWKSURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jwk{
+			{Kty: "RSA", Kid: "rsa", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{Kty: "EC", Kid: "ec", Crv: "P-256", X: b64(pad32(ecKey.X)), Y: b64(pad32(ecKey.Y))},
+		}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != testClientID || secret != "secret" || r.FormValue("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		m.mu.Lock()
+		claims := m.claims(m.nonce)
+		m.mu.Unlock()
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": m.sign(t, "RS256", "rsa", claims)})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func pad32(n *big.Int) []byte {
+	b := make([]byte, 32)
+	n.FillBytes(b)
+	return b
+}
+
+// validClaims 返回一组有效的声明, 可以按需修改
+func (m *mockIssuer) validClaims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":            m.server.URL,
+		"aud":            testClientID,
+		"sub":            "user-1",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+// sign 用签发方的密钥签发 JWT, alg 为 RS256、ES256 时使用对应的私钥
+func (m *mockIssuer) sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signing := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signing))
+	var sig []byte
+	var err error
+	switch alg {
+	case "RS256":
+		sig, err = rsa.SignPKCS1v15(rand.Reader, m.rsaKey, crypto.SHA256, digest[:])
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, m.ecKey, digest[:])
+		if err == nil {
+			sig = append(pad32(r), pad32(s)...)
+		}
+	case "HS256":
+		// 算法混淆: 用 RSA 公钥作为 HMAC 密钥
+		pub, _ := x509.MarshalPKIXPublicKey(&m.rsaKey.PublicKey)
+		mac := hmac.New(sha256.New, pub)
+		mac.Write([]byte(signing))
+		sig = mac.Sum(nil)
+	case "none":
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signing + "." + b64(sig)
+}
+
+func (m *mockIssuer) setup(t *testing.T, users map[string]string) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Auth.SessionSecret = "test-secret"
+	cfg.Auth.OIDC = config.OIDC{
+		Issuer:       m.server.URL,
+		ClientID:     testClientID,
+		ClientSecret: "secret",
+		RedirectURL:  "http://hot.test/auth/callback",
+		RoleClaim:    "roles",
+		Users:        users,
+		DefaultRole:  "viewer",
+	}
+	if err := Setup(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = Setup(config.Default())
+	})
+}
+
+func TestVerify(t *testing.T) {
+	m := newMockIssuer(t)
+	m.setup(t, nil)
+	p := get().oidc
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		token func() string
+		nonce string
+		// wantErr 期望的错误包含的文本, 为空表示校验通过
+		wantErr string
+	}{
+		{name: "good RS256", token: func() string { return m.sign(t, "RS256", "rsa", m.validClaims()) }},
+		{name: "good ES256", token: func() string { return m.sign(t, "ES256", "ec", m.validClaims()) }},
+		{
+			name: "bad signature",
+			token: func() string {
+				good := m.sign(t, "RS256", "rsa", m.validClaims())
+				saved := m.rsaKey
+				m.rsaKey = other
+				forged := m.sign(t, "RS256", "rsa", m.validClaims())
+				m.rsaKey = saved
+				// 用原 token 的头和声明, 配上其它密钥的签名
+				return good[:strings.LastIndex(good, ".")] + forged[strings.LastIndex(forged, "."):]
+			},
+			wantErr: "verify token",
+		},
+		{
+			name: "tampered claims",
+			token: func() string {
+				parts := strings.Split(m.sign(t, "RS256", "rsa", m.validClaims()), ".")
+				claims := m.validClaims()
+				claims["sub"] = "admin"
+				payload, _ := json.Marshal(claims)
+				return parts[0] + "." + b64(payload) + "." + parts[2]
+			},
+			wantErr: "verify token",
+		},
+		{
+			name: "wrong audience",
+			token: func() string {
+				claims := m.validClaims()
+				claims["aud"] = "other-client"
+				return m.sign(t, "RS256", "rsa", claims)
+			},
+			wantErr: "not issued for this client",
+		},
+		{
+			name: "audience list",
+			token: func() string {
+				claims := m.validClaims()
+				claims["aud"] = []string{"other-client", testClientID}
+				return m.sign(t, "RS256", "rsa", claims)
+			},
+		},
+		{
+			name: "wrong issuer",
+			token: func() string {
+				claims := m.validClaims()
+				claims["iss"] = "https://evil.example.com"
+				return m.sign(t, "RS256", "rsa", claims)
+			},
+			wantErr: "unexpected issuer",
+		},
+		{
+			name: "expired",
+			token: func() string {
+				claims := m.validClaims()
+				claims["exp"] = time.Now().Add(-2 * clockSkew).Unix()
+				return m.sign(t, "RS256", "rsa", claims)
+			},
+			wantErr: "expired",
+		},
+		{
+			name: "expired within clock skew",
+			token: func() string {
+				claims := m.validClaims()
+				claims["exp"] = time.Now().Add(-clockSkew / 2).Unix()
+				return m.sign(t, "RS256", "rsa", claims)
+			},
+		},
+		{
+			name: "not valid yet",
+			token: func() string {
+				claims := m.validClaims()
+				claims["nbf"] = time.Now().Add(2 * clockSkew).Unix()
+				return m.sign(t, "RS256", "rsa", claims)
+			},
+			wantErr: "not valid yet",
+		},
+		{
+			name: "missing sub",
+			token: func() string {
+				claims := m.validClaims()
+				delete(claims, "sub")
+				return m.sign(t, "RS256", "rsa", claims)
+			},
+			wantErr: "no sub",
+		},
+		{
+			name: "nonce match",
+			token: func() string {
+				claims := m.validClaims()
+				claims["nonce"] = "n-1"
+				return m.sign(t, "RS256", "rsa", claims)
+			},
+			nonce: "n-1",
+		},
+		{
+			name: "nonce mismatch",
+			token: func() string {
+				claims := m.validClaims()
+				claims["nonce"] = "n-2"
+				return m.sign(t, "RS256", "rsa", claims)
+			},
+			nonce:   "n-1",
+			wantErr: "nonce mismatch",
+		},
+		{
+			name:    "alg none",
+			token:   func() string { return m.sign(t, "none", "rsa", m.validClaims()) },
+			wantErr: "unexpected alg",
+		},
+		{
+			name:    "alg HS256 with RSA public key",
+			token:   func() string { return m.sign(t, "HS256", "rsa", m.validClaims()) },
+			wantErr: "unexpected alg",
+		},
+		{
+			name: "alg ES256 on RSA key",
+			token: func() string {
+				token := m.sign(t, "ES256", "ec", m.validClaims())
+				header, _ := json.Marshal(map[string]string{"alg": "ES256", "kid": "rsa"})
+				return b64(header) + token[strings.Index(token, "."):]
+			},
+			wantErr: "unexpected alg",
+		},
+		{
+			name:    "unknown kid",
+			token:   func() string { return m.sign(t, "RS256", "missing", m.validClaims()) },
+			wantErr: "unknown key id",
+		},
+		{name: "malformed", token: func() string { return "a.b" }, wantErr: "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := p.verify(tt.token(), tt.nonce)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("verify: %v", err)
+				}
+				if claims["sub"] != "user-1" {
+					t.Errorf("sub = %v", claims["sub"])
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrincipal(t *testing.T) {
+	m := newMockIssuer(t)
+	m.setup(t, map[string]string{"alice@example.com": "admin", "user-2": "editor"})
+	p := get().oidc
+	tests := []struct {
+		name     string
+		claims   map[string]interface{}
+		wantRole Role
+		wantName string
+	}{
+		{
+			name:     "verified email in users",
+			claims:   map[string]interface{}{"sub": "user-1", "email": "alice@example.com", "email_verified": true},
+			wantRole: Admin, wantName: "alice@example.com",
+		},
+		{
+			name:     "unverified email ignored",
+			claims:   map[string]interface{}{"sub": "user-9", "email": "alice@example.com", "email_verified": false},
+			wantRole: Viewer, wantName: "alice@example.com",
+		},
+		{
+			name:     "email_verified missing",
+			claims:   map[string]interface{}{"sub": "user-9", "email": "alice@example.com"},
+			wantRole: Viewer, wantName: "alice@example.com",
+		},
+		{
+			name:     "sub in users",
+			claims:   map[string]interface{}{"sub": "user-2", "preferred_username": "bob"},
+			wantRole: Editor, wantName: "bob",
+		},
+		{
+			name:     "highest role claim",
+			claims:   map[string]interface{}{"sub": "user-3", "roles": []interface{}{"viewer", "editor", "unknown"}},
+			wantRole: Editor, wantName: "user-3",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := p.principal(tt.claims)
+			if got.Role != tt.wantRole || got.Name != tt.wantName {
+				t.Errorf("principal = %s %s, want %s %s", got.Name, got.Role, tt.wantName, tt.wantRole)
+			}
+			if got.ID != "oidc:"+tt.claims["sub"].(string) {
+				t.Errorf("ID = %q", got.ID)
+			}
+		})
+	}
+}
+
+// login 走一遍 /auth/login 和 /auth/callback, 返回回调的响应
+func (m *mockIssuer) login(t *testing.T, code string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	LoginHandler(rec, httptest.NewRequest(http.MethodGet, "/auth/login?next=/admin", nil))
+	if rec.Code != http.StatusFound {
+		t.Fatalf("login status = %d", rec.Code)
+	}
+	target, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(target.String(), m.server.URL+"/authorize") {
+		t.Fatalf("login redirect = %q", rec.Header().Get("Location"))
+	}
+	m.mu.Lock()
+	m.nonce = target.Query().Get("nonce")
+	m.mu.Unlock()
+	callback := httptest.NewRequest(http.MethodGet, "/auth/callback?code="+code+"&state="+target.Query().Get("state"), nil)
+	for _, cookie := range rec.Result().Cookies() {
+		callback.AddCookie(cookie)
+	}
+	rec = httptest.NewRecorder()
+	CallbackHandler(rec, callback)
+	return rec
+}
+
+func sessionOf(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == sessionCookie && cookie.Value != "" {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestLoginFlow(t *testing.T) {
+	m := newMockIssuer(t)
+	m.setup(t, map[string]string{"alice@example.com": "admin"})
+	m.claims = func(nonce string) map[string]interface{} {
+		claims := m.validClaims()
+		claims["nonce"] = nonce
+		return claims
+	}
+	rec := m.login(t, "good-code")
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "/admin" {
+		t.Fatalf("callback = %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	cookie := sessionOf(rec)
+	if cookie == nil {
+		t.Fatal("no session cookie")
+	}
+	request := httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	request.AddCookie(cookie)
+	p, role, err := Identify(request)
+	if err != nil || p == nil {
+		t.Fatalf("Identify = %v, %v", p, err)
+	}
+	if role != Admin || p.ID != "oidc:user-1" || p.Method != "session" {
+		t.Errorf("session principal = %+v", p)
+	}
+
+	// 签发方写入的 nonce 与登录时的不一致
+	m.claims = func(string) map[string]interface{} {
+		claims := m.validClaims()
+		claims["nonce"] = "replayed"
+		return claims
+	}
+	rec = m.login(t, "good-code")
+	if rec.Code != http.StatusUnauthorized || sessionOf(rec) != nil {
+		t.Errorf("nonce mismatch callback = %d", rec.Code)
+	}
+
+	rec = m.login(t, "bad-code")
+	if rec.Code != http.StatusBadGateway || sessionOf(rec) != nil {
+		t.Errorf("bad code callback = %d", rec.Code)
+	}
+}
+
+func TestCallbackRejectsForgedState(t *testing.T) {
+	m := newMockIssuer(t)
+	m.setup(t, nil)
+	rec := httptest.NewRecorder()
+	CallbackHandler(rec, httptest.NewRequest(http.MethodGet, "/auth/callback?code=good-code&state=guess", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d", rec.Code)
+	}
+}
+
+func TestBearerIDToken(t *testing.T) {
+	m := newMockIssuer(t)
+	m.setup(t, map[string]string{"user-1": "editor"})
+	request := httptest.NewRequest(http.MethodGet, "/api/hot", nil)
+	request.Header.Set("Authorization", "Bearer "+m.sign(t, "ES256", "ec", m.validClaims()))
+	p, role, err := Identify(request)
+	if err != nil || p == nil || role != Editor || p.Method != "oidc" {
+		t.Fatalf("Identify = %+v %s %v", p, role, err)
+	}
+	claims := m.validClaims()
+	claims["aud"] = "other-client"
+	request.Header.Set("Authorization", "Bearer "+m.sign(t, "ES256", "ec", claims))
+	if _, _, err := Identify(request); err == nil {
+		t.Error("token for another client accepted")
+	}
+}
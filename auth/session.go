@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sessionCookie OIDC 登录后的会话 cookie
+const sessionCookie = "hot_session"
+
+// session 会话 cookie 的内容, 角色在登录时确定, 修改配置后需重新登录才生效
+type session struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Role    string `json:"role"`
+	Expires int64  `json:"exp"`
+}
+
+// sessionCodec 用 HMAC-SHA256 签名会话, cookie 值为 base64(json).base64(签名)
+type sessionCodec struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func (c *sessionCodec) sign(payload string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issue 写入会话 cookie
+func (c *sessionCodec) issue(writer http.ResponseWriter, request *http.Request, p *Principal) {
+	data, _ := json.Marshal(session{ID: p.ID, Name: p.Name, Role: p.Role.String(), Expires: time.Now().Add(c.ttl).Unix()})
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	http.SetCookie(writer, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    payload + "." + c.sign(payload),
+		Path:     "/",
+		MaxAge:   int(c.ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   request.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func clearSession(writer http.ResponseWriter) {
+	http.SetCookie(writer, &http.Cookie{Name: sessionCookie, Value: "", Path: "/", MaxAge: -1})
+}
+
+func (c *sessionCodec) Authenticate(request *http.Request) (*Principal, error) {
+	cookie, err := request.Cookie(sessionCookie)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(c.sign(parts[0]))) {
+		// 密钥变化(如重启后随机生成了新密钥)时旧会话失效, 按未登录处理
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil
+	}
+	var s session
+	// 没有 ID 的旧会话需要重新登录
+	if json.Unmarshal(data, &s) != nil || time.Now().Unix() > s.Expires || s.ID == "" {
+		return nil, nil
+	}
+	role, err := ParseRole(s.Role)
+	if err != nil {
+		return nil, fmt.Errorf("session: %w", err)
+	}
+	return &Principal{ID: s.ID, Name: s.Name, Role: role, Method: "session"}, nil
+}
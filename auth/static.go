@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"goCrawlerHot/config"
+	"net/http"
+	"strings"
+)
+
+type token struct {
+	name  string
+	value []byte
+	role  Role
+}
+
+// tokenAuth 静态 API 令牌, 通过 Authorization: Bearer <token> 或 X-API-Token 请求头传入
+type tokenAuth struct {
+	tokens []token
+	// jwt 配置了 OIDC 时, 形如 JWT 的未知令牌交给 OIDC 校验
+	jwt bool
+}
+
+func newTokenAuth(tokens []config.APIToken) (*tokenAuth, error) {
+	a := &tokenAuth{}
+	for i, t := range tokens {
+		role, err := ParseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("auth.tokens[%d].role: %w", i, err)
+		}
+		name := t.Name
+		if name == "" {
+			name = fmt.Sprintf("token-%d", i)
+		}
+		a.tokens = append(a.tokens, token{name: name, value: []byte(t.Token), role: role})
+	}
+	return a, nil
+}
+
+// bearerToken 返回请求携带的 Bearer 令牌或 X-API-Token
+func bearerToken(request *http.Request) string {
+	header := request.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return request.Header.Get("X-API-Token")
+}
+
+func (a *tokenAuth) Authenticate(request *http.Request) (*Principal, error) {
+	got := bearerToken(request)
+	if got == "" {
+		return nil, nil
+	}
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare([]byte(got), t.value) == 1 {
+			return &Principal{ID: "token:" + t.name, Name: t.name, Role: t.role, Method: "token"}, nil
+		}
+	}
+	if a.jwt && strings.Count(got, ".") == 2 {
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown api token")
+}
+
+type basicUser struct {
+	// sum 密码的 SHA-256 摘要, 明文密码在加载时计算
+	sum  []byte
+	role Role
+}
+
+// basicAuth HTTP basic 认证
+type basicAuth struct {
+	users map[string]basicUser
+}
+
+func newBasicAuth(users []config.BasicUser) (*basicAuth, error) {
+	a := &basicAuth{users: map[string]basicUser{}}
+	for i, u := range users {
+		role, err := ParseRole(u.Role)
+		if err != nil {
+			return nil, fmt.Errorf("auth.users[%d].role: %w", i, err)
+		}
+		var sum []byte
+		if strings.HasPrefix(u.Password, "sha256:") {
+			sum, err = hex.DecodeString(strings.TrimPrefix(u.Password, "sha256:"))
+			if err != nil || len(sum) != sha256.Size {
+				return nil, fmt.Errorf("auth.users[%d].password: invalid sha256 digest", i)
+			}
+		} else {
+			digest := sha256.Sum256([]byte(u.Password))
+			sum = digest[:]
+		}
+		a.users[u.Username] = basicUser{sum: sum, role: role}
+	}
+	return a, nil
+}
+
+func (a *basicAuth) Authenticate(request *http.Request) (*Principal, error) {
+	username, password, ok := request.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	user, found := a.users[username]
+	sum := sha256.Sum256([]byte(password))
+	// 用户不存在时也做一次比较, 避免通过耗时判断用户是否存在
+	expected := user.sum
+	if !found {
+		expected = make([]byte, sha256.Size)
+	}
+	if subtle.ConstantTimeCompare(sum[:], expected) != 1 || !found {
+		return nil, fmt.Errorf("invalid password for %q", username)
+	}
+	return &Principal{ID: "basic:" + username, Name: username, Role: user.role, Method: "basic"}, nil
+}
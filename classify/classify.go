@@ -0,0 +1,54 @@
+// Package classify 按配置创建条目分类器
+package classify
+
+import (
+	"fmt"
+	"goCrawlerHot/config"
+	"goCrawlerHot/cralwer"
+	"time"
+)
+
+// Categories 内置分类的显示顺序, 配置中新增的分类按名称排在后面
+var Categories = []string{"politics", "finance", "tech", "entertainment", "sports", "games"}
+
+// Open 按配置创建分类器, none 时返回 nil
+func Open(cfg config.Classifier) (cralwer.Classifier, error) {
+	keywords := NewKeywords(cfg)
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	if (cfg.Type == "http" || cfg.Type == "command") && timeout <= 0 {
+		return nil, fmt.Errorf("classifier timeout must be positive")
+	}
+	switch cfg.Type {
+	case "", "keywords":
+		return keywords, nil
+	case "http":
+		return fallback{primary: httpClassifier{url: cfg.URL, timeout: timeout}, secondary: keywords}, nil
+	case "command":
+		if len(cfg.Command) == 0 {
+			return nil, fmt.Errorf("classifier command is required")
+		}
+		return fallback{primary: commandClassifier{command: cfg.Command, timeout: timeout}, secondary: keywords}, nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown classifier type %q", cfg.Type)
+	}
+}
+
+// fallback 本地模型调用失败时改用关键词词典, 模型服务停止不会让条目失去分类
+type fallback struct {
+	primary   cralwer.Classifier
+	secondary cralwer.Classifier
+}
+
+func (f fallback) Classify(source string, items []cralwer.ClassifyItem) ([]cralwer.Label, error) {
+	labels, err := f.primary.Classify(source, items)
+	if err == nil && len(labels) == len(items) {
+		return labels, nil
+	}
+	if err == nil {
+		err = fmt.Errorf("got %d labels for %d items", len(labels), len(items))
+	}
+	cralwer.Logln(source, "classifier err, using keywords:", err)
+	return f.secondary.Classify(source, items)
+}
@@ -0,0 +1,168 @@
+package classify
+
+import (
+	"goCrawlerHot/config"
+	"goCrawlerHot/cralwer"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// builtinCategories 内置的分类词典, 英文词按整词匹配, 中文词按子串匹配, 都不区分大小写
+var builtinCategories = map[string][]string{
+	"politics": {"外交部", "国务院", "国务委员", "总统", "总理", "首相", "大选", "选举", "议会", "国会", "人大", "政协", "部长", "制裁",
+		"外交", "领导人", "峰会", "联合国", "北约", "白宫", "军演", "战争", "停火", "政府", "政策",
+		"president", "election", "senate", "congress", "parliament", "minister", "government", "sanctions", "nato", "ceasefire", "war"},
+	"finance": {"股市", "A股", "港股", "美股", "基金", "央行", "利率", "降息", "加息", "通胀", "GDP", "财报", "营收", "IPO", "汇率",
+		"人民币", "比特币", "房价", "楼市", "经济", "涨停", "跌停", "市值",
+		"stock", "stocks", "inflation", "earnings", "bitcoin", "crypto", "economy", "fed", "nasdaq"},
+	"tech": {"科技", "芯片", "手机", "人工智能", "AI", "大模型", "ChatGPT", "华为", "小米", "苹果", "互联网", "软件", "开源", "程序员",
+		"编程", "算法", "数据库", "Linux", "Python", "JavaScript", "Java", "GitHub", "5G", "操作系统", "漏洞", "黑客", "机器人",
+		"卫星", "火箭", "航天", "新能源", "自动驾驶",
+		"software", "open source", "chip", "gpu", "llm", "programming", "developer", "startup", "rust", "kubernetes"},
+	"entertainment": {"明星", "演员", "歌手", "电影", "电视剧", "综艺", "票房", "导演", "演唱会", "专辑", "新歌", "娱乐圈", "官宣",
+		"恋情", "粉丝", "偶像", "动漫", "热播", "主演", "定档", "杀青",
+		"movie", "film", "actor", "actress", "singer", "album", "concert", "netflix", "oscar", "grammy"},
+	"sports": {"足球", "篮球", "NBA", "CBA", "世界杯", "奥运", "冠军", "联赛", "国足", "中超", "欧冠", "英超", "网球", "乒乓",
+		"羽毛球", "排球", "游泳", "田径", "马拉松", "球员", "主教练", "进球", "夺冠", "决赛",
+		"football", "soccer", "basketball", "olympic", "olympics", "championship", "tennis", "fifa"},
+	"games": {"游戏", "原神", "王者荣耀", "英雄联盟", "电竞", "手游", "Steam", "Switch", "PS5", "Xbox",
+		"game", "games", "gaming", "esports", "nintendo"},
+}
+
+// builtinSensitive 内置的敏感词, 命中的条目在页面上默认隐藏
+var builtinSensitive = []string{"色情", "裸照", "约炮", "成人视频", "黄网", "赌博", "博彩", "赌场", "毒品", "吸毒", "自杀", "自残",
+	"血腥", "虐杀", "虐待", "枪击", "恐袭", "恐怖袭击", "斩首",
+	"porn", "nsfw", "nude", "nudes", "gambling", "casino", "suicide", "gore", "beheading"}
+
+// builtinSourceCategories 内容单一的数据源, 全部条目都属于这些分类
+var builtinSourceCategories = map[string][]string{
+	"CrawlerGithub":  {"tech"},
+	"CrawlerCSDN":    {"tech"},
+	"Crawler52PoJie": {"tech"},
+}
+
+// Keywords 关键词词典分类器, 标题包含分类的任一关键词即属于该分类
+type Keywords struct {
+	categories map[string][]string
+	sensitive  []string
+	sources    map[string][]string
+}
+
+// NewKeywords 合并内置词典和配置中的词典
+func NewKeywords(cfg config.Classifier) *Keywords {
+	k := &Keywords{categories: map[string][]string{}, sources: map[string][]string{}}
+	for _, dictionary := range []map[string][]string{builtinCategories, cfg.Categories} {
+		for category, words := range dictionary {
+			k.categories[category] = append(k.categories[category], normalize(words)...)
+		}
+	}
+	k.sensitive = normalize(append(append([]string(nil), builtinSensitive...), cfg.Sensitive...))
+	for _, sources := range []map[string][]string{builtinSourceCategories, cfg.SourceCategories} {
+		for source, categories := range sources {
+			k.sources[source] = append(k.sources[source], categories...)
+		}
+	}
+	return k
+}
+
+// normalize 转为小写并去掉空词
+func normalize(words []string) []string {
+	var result []string
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			result = append(result, word)
+		}
+	}
+	return result
+}
+
+func (k *Keywords) Classify(source string, items []cralwer.ClassifyItem) ([]cralwer.Label, error) {
+	labels := make([]cralwer.Label, len(items))
+	for i, item := range items {
+		title := strings.ToLower(item.Title)
+		seen := map[string]bool{}
+		for _, category := range k.sources[source] {
+			seen[category] = true
+		}
+		for category, words := range k.categories {
+			if !seen[category] && containsAny(title, words) {
+				seen[category] = true
+			}
+		}
+		labels[i] = cralwer.Label{Categories: Sort(keys(seen)), Sensitive: containsAny(title, k.sensitive)}
+	}
+	return labels, nil
+}
+
+func keys(set map[string]bool) []string {
+	result := make([]string, 0, len(set))
+	for key := range set {
+		result = append(result, key)
+	}
+	return result
+}
+
+// Sort 按内置分类的顺序排列, 其余分类按名称排在后面
+func Sort(categories []string) []string {
+	order := map[string]int{}
+	for i, category := range Categories {
+		order[category] = i + 1
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		oi, oj := order[categories[i]], order[categories[j]]
+		if oi == 0 || oj == 0 {
+			if oi != oj {
+				return oi != 0
+			}
+			return categories[i] < categories[j]
+		}
+		return oi < oj
+	})
+	return categories
+}
+
+// containsAny text 是否包含任一关键词, 只由字母和数字组成的关键词需要整词出现, 避免 ai 命中 said
+func containsAny(text string, words []string) bool {
+	for _, word := range words {
+		if containsWord(text, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsWord(text, word string) bool {
+	if !isASCIIWord(word) {
+		return strings.Contains(text, word)
+	}
+	for start := 0; ; {
+		index := strings.Index(text[start:], word)
+		if index < 0 {
+			return false
+		}
+		begin, end := start+index, start+index+len(word)
+		if !isWordByte(text, begin-1) && !isWordByte(text, end) {
+			return true
+		}
+		start = begin + 1
+	}
+}
+
+func isASCIIWord(word string) bool {
+	for _, r := range word {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordByte text[i] 是否为 ASCII 字母或数字, 越界时为 false, 中文字符的字节不算
+func isWordByte(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return false
+	}
+	c := text[i]
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,119 @@
+package classify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goCrawlerHot/cralwer"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// request 发给本地模型的请求体, 一次包含一个数据源的全部条目
+type request struct {
+	Source string                 `json:"source"`
+	Items  []cralwer.ClassifyItem `json:"items"`
+}
+
+// response 本地模型返回的结果, labels 与 items 一一对应
+type response struct {
+	Labels []cralwer.Label `json:"labels"`
+}
+
+// maxResponseSize 本地模型返回结果的最大字节数
+const maxResponseSize = 4 << 20
+
+var errResponseTooLarge = errors.New("classifier response too large")
+
+// limitedBuffer 最多保存 limit 字节, 超过时返回 errResponseTooLarge, discard 为 true 时丢弃多余的内容
+// 不内嵌 bytes.Buffer, 否则 io.Copy 会改用它的 ReadFrom 而绕过限制
+type limitedBuffer struct {
+	buf     bytes.Buffer
+	limit   int
+	discard bool
+	// exceeded 输出是否超过了 limit
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.limit {
+		b.exceeded = true
+		if b.discard {
+			_, _ = b.buf.Write(p[:b.limit-b.buf.Len()])
+			return len(p), nil
+		}
+		return 0, errResponseTooLarge
+	}
+	return b.buf.Write(p)
+}
+
+// httpClassifier 以 JSON POST 条目到本地模型服务
+type httpClassifier struct {
+	url     string
+	timeout time.Duration
+}
+
+func (c httpClassifier) Classify(source string, items []cralwer.ClassifyItem) ([]cralwer.Label, error) {
+	body, err := json.Marshal(request{Source: source, Items: items})
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: c.timeout}
+	res, err := client.Post(c.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var result response
+	err = json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(&result)
+	if closeErr := res.Body.Close(); closeErr != nil {
+		cralwer.Logln("classifier close err:", closeErr)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("classifier status code error: %d %s", res.StatusCode, res.Status)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode classifier response: %w", err)
+	}
+	return result.Labels, nil
+}
+
+// commandClassifier 运行本地程序, 请求写入标准输入, 从标准输出读取结果
+type commandClassifier struct {
+	command []string
+	timeout time.Duration
+}
+
+func (c commandClassifier) Classify(source string, items []cralwer.ClassifyItem) ([]cralwer.Label, error) {
+	body, err := json.Marshal(request{Source: source, Items: items})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.command[0], c.command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	stdout := &limitedBuffer{limit: maxResponseSize}
+	stderr := &limitedBuffer{limit: 4096, discard: true}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	if stdout.exceeded {
+		return nil, errResponseTooLarge
+	}
+	if err != nil {
+		if message := strings.TrimSpace(stderr.buf.String()); message != "" {
+			return nil, fmt.Errorf("%w: %s", err, message)
+		}
+		return nil, err
+	}
+	var result response
+	if err := json.Unmarshal(stdout.buf.Bytes(), &result); err != nil {
+		return nil, fmt.Errorf("decode classifier output: %w", err)
+	}
+	return result.Labels, nil
+}
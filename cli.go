@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"goCrawlerHot/config"
+	"goCrawlerHot/cralwer"
+	"goCrawlerHot/digest"
+	"goCrawlerHot/export"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// commands 子命令, 参数为配置文件路径和子命令之后的参数, 返回进程退出码
+var commands = map[string]func(configPath string, args []string) int{
+	"serve":           serve,
+	"crawl":           crawl,
+	"sources":         sources,
+	"export":          exportResults,
+	"digest":          makeDigest,
+	"validate-config": validateConfig,
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `用法: goCrawlerHot [-config config.json] <command> [flags]
+
+命令:
+  serve [-addr :8080]                          启动 web 服务并定时抓取(默认命令)
+  crawl -once [-source X] [-format json] [-write]
+                                               抓取一次并输出到标准输出
+  crawl                                        不启动 web 服务, 只定时抓取
+  sources list                                 列出全部数据源
+  export -format csv|xlsx|json|md [-source X] [-from T] [-to T] [-output file]
+                                               导出当前热榜, 指定 -from/-to 时导出历史快照
+  digest -period day|week [-date 2006-01-02] [-format md|html] [-send]
+                                               根据历史快照生成日报或周报, -send 时通过配置的方式投递
+  validate-config                              检查配置文件
+`)
+}
+
+// openOutput 返回输出文件, path 为空或 - 时使用标准输出
+func openOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// crawl 抓取数据, -once 时抓取一次并输出结果, 否则定时抓取直到收到退出信号
+func crawl(_ string, args []string) int {
+	flags := flag.NewFlagSet("crawl", flag.ExitOnError)
+	once := flags.Bool("once", false, "只抓取一次并输出结果")
+	source := flags.String("source", "", "只抓取指定数据源, 多个用逗号分隔")
+	format := flags.String("format", "json", "输出格式: "+strings.Join(export.Formats, ", "))
+	write := flags.Bool("write", false, "同时把结果写入存储")
+	_ = flags.Parse(args)
+
+	if !*once {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		done := runScheduled(ctx)
+		<-ctx.Done()
+		fmt.Println("正在退出, 等待抓取结束...")
+		select {
+		case <-done:
+			fmt.Println("已退出")
+		case <-time.After(shutdownTimeout):
+			fmt.Println("等待抓取超时, 强制退出")
+		}
+		return 0
+	}
+	var names []string
+	if *source != "" {
+		names = strings.Split(*source, ",")
+	}
+	// 抓取日志改到标准错误, 让标准输出只有结果, 方便管道处理; 后台的代理检查等在导出之后输出的日志也不会混入结果
+	cralwer.SetLogOutput(os.Stderr)
+	results, reports, err := cralwer.Crawl(names)
+	if err == nil && *write {
+		cralwer.WriteResults(results)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	err = export.Write(os.Stdout, *format, results)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "export err:", err)
+		return 1
+	}
+	// 有数据源抓取失败时以非零状态退出, 方便 cron 报警
+	code := 0
+	for _, report := range reports {
+		if report.Error != "" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", report.Source, report.Error)
+			code = 1
+		}
+	}
+	return code
+}
+
+// sources 列出数据源
+func sources(_ string, args []string) int {
+	if len(args) != 1 || args[0] != "list" {
+		usage()
+		return 2
+	}
+	for _, name := range cralwer.Sources() {
+		if cralwer.Enabled(name) {
+			fmt.Println(name)
+		} else {
+			fmt.Println(name, "(disabled)")
+		}
+	}
+	return 0
+}
+
+// exportResults 导出存储中的热榜
+func exportResults(_ string, args []string) int {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", "json", "导出格式: "+strings.Join(export.Formats, ", "))
+	source := flags.String("source", "", "只导出指定数据源")
+	from := flags.String("from", "", "导出历史快照的开始时间, 如 2006-01-02 或 2006-01-02 15:04:05")
+	to := flags.String("to", "", "导出历史快照的结束时间, 只有日期时包含当天")
+	output := flags.String("output", "", "输出文件, 默认输出到标准输出")
+	_ = flags.Parse(args)
+
+	results, err := export.Load(*source, *from, *to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "load results err:", err)
+		return 1
+	}
+	file, err := openOutput(*output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	err = export.Write(file, *format, results)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "export err:", err)
+		return 1
+	}
+	return 0
+}
+
+// makeDigest 生成日报或周报, 默认统计昨天(日报)或本周(周报)
+func makeDigest(_ string, args []string) int {
+	flags := flag.NewFlagSet("digest", flag.ExitOnError)
+	period := flags.String("period", digest.Day, "统计周期: day 或 week")
+	date := flags.String("date", "", "统计周期内的任意一天, 默认日报为昨天, 周报为今天")
+	format := flags.String("format", "md", "输出格式: md 或 html")
+	send := flags.Bool("send", false, "通过配置的投递方式发送, 不输出到标准输出")
+	_ = flags.Parse(args)
+
+	day := time.Now()
+	if *period == digest.Day {
+		day = day.AddDate(0, 0, -1)
+	}
+	if *date != "" {
+		var err error
+		day, err = time.ParseInLocation("2006-01-02", *date, time.Local)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid date:", err)
+			return 2
+		}
+	}
+	start, end, err := digest.Range(*period, day)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	cfg := config.Get().Digest
+	d, err := digest.Build(*period, start, end, cfg.TopN)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "digest err:", err)
+		return 1
+	}
+	if *send {
+		err = digest.Deliver(d, cfg.Notifiers)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+	output := digest.Markdown(d)
+	if *format == "html" {
+		output, err = digest.HTML(d)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "render err:", err)
+			return 1
+		}
+	}
+	fmt.Print(output)
+	return 0
+}
+
+// validateConfig 检查配置文件能否解析, 取值是否合法, 数据源名称是否存在
+func validateConfig(configPath string, _ []string) int {
+	cfg, err := config.LoadFile(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	errs := config.Validate(cfg)
+	known := map[string]bool{}
+	for _, name := range cralwer.Sources() {
+		known[name] = true
+	}
+	var unknown []string
+	for name := range cfg.Sources {
+		if !known[name] {
+			unknown = append(unknown, "sources."+name)
+		}
+	}
+	for name := range cfg.Ranking.Weights {
+		if !known[name] {
+			unknown = append(unknown, "ranking.weights."+name)
+		}
+	}
+	for _, name := range cfg.Dashboard.Order {
+		if !known[name] {
+			unknown = append(unknown, "dashboard.order."+name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, field := range unknown {
+		errs = append(errs, fmt.Errorf("%s: unknown source", field))
+	}
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if len(errs) > 0 {
+		return 1
+	}
+	fmt.Println(configPath, "ok")
+	return 0
+}
@@ -0,0 +1,674 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source 单个数据源的配置, 以爬虫方法名为键
+type Source struct {
+	// Cookies 初始 cookie, 会话创建时写入 cookie jar, 需同时配置 LandingURL 以确定 cookie 所属站点
+	Cookies map[string]string `json:"cookies,omitempty"`
+	// LandingURL 预热页面, 会话创建和失效时先访问它以获取 cookie
+	LandingURL string `json:"landing_url,omitempty"`
+	// CookieParams 查询参数名到 cookie 名的映射, 请求时用 cookie 的值替换查询参数, 如抖音的 msToken
+	CookieParams map[string]string `json:"cookie_params,omitempty"`
+	// ChallengeMarkers 追加的验证页标记, 响应页面的标题或重定向地址包含其中任一文本时视为会话失效
+	ChallengeMarkers []string `json:"challenge_markers,omitempty"`
+	// Proxies 该数据源使用的代理, 为空时使用全局代理, ["direct"] 表示直连
+	Proxies []string `json:"proxies,omitempty"`
+	// Disabled 停用后不再定时抓取, 页面和接口不再显示该数据源
+	Disabled bool `json:"disabled,omitempty"`
+	// URL 替换爬虫内置的请求地址, 分页的数据源用 {page} 表示页码
+	URL string `json:"url,omitempty"`
+	// Headers 额外的请求头, 覆盖爬虫内置的同名请求头
+	Headers map[string]string `json:"headers,omitempty"`
+	// Interval 定时抓取的间隔分钟数, 0 表示使用默认的 10 分钟
+	Interval int `json:"interval,omitempty"`
+	// Selectors 替换 HTML 数据源内置的 CSS 选择器, 可用的键见 /admin 页面
+	Selectors map[string]string `json:"selectors,omitempty"`
+	// Filter 只对该数据源生效的过滤规则, 与全局的 filter 同时生效
+	Filter *FilterRule `json:"filter,omitempty"`
+}
+
+// SourceOverride 管理页面可以修改的数据源配置, 集群模式下保存在共享存储中, 覆盖各实例配置文件中的同名字段,
+// cookie 和代理等只来自各实例的配置文件
+type SourceOverride struct {
+	Disabled  bool              `json:"disabled,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Interval  int               `json:"interval,omitempty"`
+	Selectors map[string]string `json:"selectors,omitempty"`
+}
+
+// Override 返回数据源配置中可以在管理页面修改的部分
+func (s Source) Override() SourceOverride {
+	return SourceOverride{
+		Disabled:  s.Disabled,
+		URL:       s.URL,
+		Headers:   s.Headers,
+		Interval:  s.Interval,
+		Selectors: s.Selectors,
+	}
+}
+
+// Apply 用 o 替换数据源配置中的同名字段
+func (o SourceOverride) Apply(s Source) Source {
+	s.Disabled = o.Disabled
+	s.URL = o.URL
+	s.Headers = o.Headers
+	s.Interval = o.Interval
+	s.Selectors = o.Selectors
+	return s
+}
+
+// FilterRule 条目过滤规则, 条目满足任一条件即被过滤, 在解析之后、写入存储之前执行
+type FilterRule struct {
+	// Keywords 标题包含其中任一关键词时过滤, 不区分大小写
+	Keywords []string `json:"keywords,omitempty"`
+	// Patterns 标题匹配其中任一正则表达式时过滤
+	Patterns []string `json:"patterns,omitempty"`
+	// Domains 链接的域名为其中之一或其子域名时过滤
+	Domains []string `json:"domains,omitempty"`
+	// Positions 过滤榜单中这些位置的条目, 从 1 开始, 如微博置顶的第 1 条
+	Positions []int `json:"positions,omitempty"`
+}
+
+// Classifier 条目分类和敏感内容标记, 在过滤之后、写入存储之前执行
+type Classifier struct {
+	// Type keywords(默认) 按关键词词典分类, http 调用本地模型服务, command 运行本地程序, none 不分类,
+	// http 和 command 调用失败时改用关键词词典
+	Type string `json:"type"`
+	// Categories 分类到关键词的词典, 与内置词典合并, 同名分类追加关键词
+	Categories map[string][]string `json:"categories,omitempty"`
+	// Sensitive 追加的敏感词, 标题包含其中任一词时标记为敏感内容
+	Sensitive []string `json:"sensitive,omitempty"`
+	// SourceCategories 数据源的固定分类, 如 GitHub 的条目都属于 tech, 与内置值合并
+	SourceCategories map[string][]string `json:"source_categories,omitempty"`
+	// URL http 方式的地址, 以 JSON POST 一个数据源的全部条目
+	URL string `json:"url,omitempty"`
+	// Command command 方式运行的程序和参数, 从标准输入读取请求, 向标准输出写入结果, 格式与 http 相同
+	Command []string `json:"command,omitempty"`
+	// Timeout http 和 command 方式每次调用的超时秒数, 必须大于 0
+	Timeout int `json:"timeout"`
+}
+
+// HostLimit 单个站点的限速配置
+type HostLimit struct {
+	// RatePerSecond 每秒允许的请求数, 令牌桶的填充速度
+	RatePerSecond float64 `json:"rate_per_second"`
+	// Burst 令牌桶容量, 即允许的突发请求数
+	Burst int `json:"burst"`
+	// MaxConcurrent 同时进行的最大请求数
+	MaxConcurrent int `json:"max_concurrent"`
+}
+
+// Politeness 抓取礼貌性控制
+type Politeness struct {
+	// Default 未单独配置的站点使用的限速
+	Default HostLimit `json:"default"`
+	// Hosts 按站点(如 blog.csdn.net)单独配置的限速
+	Hosts map[string]HostLimit `json:"hosts,omitempty"`
+	// RespectRobots 是否遵守 robots.txt
+	RespectRobots bool `json:"respect_robots"`
+	// MaxWorkers 同时运行的爬虫数上限
+	MaxWorkers int `json:"max_workers"`
+}
+
+// Proxy 出口代理配置
+type Proxy struct {
+	// URLs 代理地址, 支持 http、https 和 socks5, 配置多个时轮换使用
+	URLs []string `json:"urls,omitempty"`
+	// HealthCheckURL 健康检查时通过代理访问的地址
+	HealthCheckURL string `json:"health_check_url,omitempty"`
+	// HealthCheckInterval 健康检查间隔秒数, 0 表示不做定时检查, 只在请求失败时切换代理
+	HealthCheckInterval int `json:"health_check_interval,omitempty"`
+}
+
+// Notifier 摘要的投递方式
+type Notifier struct {
+	// Type 投递方式: file 写入目录, webhook 以 JSON POST 到地址, smtp 发送 HTML 邮件
+	Type string `json:"type"`
+	// Dir file 方式的输出目录
+	Dir string `json:"dir,omitempty"`
+	// URL webhook 地址, 请求体为 {"title": ..., "markdown": ..., "html": ...}
+	URL string `json:"url,omitempty"`
+	// Addr SMTP 服务器地址, 如 smtp.example.com:587
+	Addr     string   `json:"addr,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+// Digest 日报和周报配置
+type Digest struct {
+	// Daily 每天生成前一天的日报
+	Daily bool `json:"daily"`
+	// Weekly 每周一生成上一周的周报
+	Weekly bool `json:"weekly"`
+	// At 每天生成摘要的时间, 如 08:00
+	At string `json:"at"`
+	// TopN 每个榜单保留的条数
+	TopN int `json:"top_n"`
+	// Notifiers 摘要生成后的投递方式
+	Notifiers []Notifier `json:"notifiers,omitempty"`
+}
+
+// Storage 抓取结果的存储, 多个实例连接同一个 postgres 或 redis 时共享数据
+type Storage struct {
+	// Type 存储类型: file(默认, result.json 和 history 目录)、sqlite、postgres、redis
+	Type string `json:"type"`
+	// DSN 连接信息: file 为数据目录(默认工作目录), sqlite 为数据库文件, postgres 为连接串, redis 为 redis:// 地址
+	DSN string `json:"dsn,omitempty"`
+	// Prefix redis 键前缀
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// Cluster 多实例部署, 各实例需使用同一个存储
+type Cluster struct {
+	// Enabled 开启后只有持有租约的实例(leader)定时抓取和生成摘要, 其它实例只提供页面和接口
+	Enabled bool `json:"enabled"`
+	// NodeID 实例标识, 为空时使用主机名和进程号
+	NodeID string `json:"node_id,omitempty"`
+	// LeaseTTL 租约有效秒数, leader 每隔三分之一的时间续期, 超时未续期时由其它实例接手
+	LeaseTTL int `json:"lease_ttl"`
+}
+
+// Ranking 全平台热度榜, 把各热榜的条目归一化后统一排序
+type Ranking struct {
+	// Method 归一化方式: rank 按榜内排名, zscore 按热度的标准分(没有热度的榜按排名计算)
+	Method string `json:"method"`
+	// Weights 数据源权重, 未配置的数据源为 1
+	Weights map[string]float64 `json:"weights,omitempty"`
+	// HalfLife 时间衰减的半衰期分钟数, 从话题首次上榜算起, 0 表示不衰减
+	HalfLife int `json:"half_life"`
+	// TopN 全平台榜的条数
+	TopN int `json:"top_n"`
+}
+
+// Dashboard 页面的默认展示方式, 用户可以在页面上修改并保存到 cookie
+type Dashboard struct {
+	// Order 数据源的默认顺序, 未列出的数据源按内置顺序排在后面
+	Order []string `json:"order,omitempty"`
+	// Layout 默认布局: tabs 标签页, grid 多列网格
+	Layout string `json:"layout"`
+}
+
+// APIToken 静态 API 令牌, 通过 Authorization: Bearer 或 X-API-Token 请求头传入
+type APIToken struct {
+	// Name 令牌的用途, 显示在日志和 /auth/me 中
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	// Role viewer、editor 或 admin
+	Role string `json:"role"`
+}
+
+// BasicUser basic 认证的用户
+type BasicUser struct {
+	Username string `json:"username"`
+	// Password 明文密码, 或以 sha256: 开头的密码 SHA-256 十六进制摘要
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// OIDC 通过 OpenID Connect 登录, 同时接受该签发方签发的 ID Token 作为 Bearer 令牌
+type OIDC struct {
+	// Issuer 签发方地址, 从 <issuer>/.well-known/openid-configuration 读取端点, 为空时不启用
+	Issuer       string `json:"issuer,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+	// RedirectURL 登录回调地址, 如 https://hot.example.com/auth/callback
+	RedirectURL string `json:"redirect_url,omitempty"`
+	// Scopes 额外请求的 scope, openid、email 和 profile 总是会请求
+	Scopes []string `json:"scopes,omitempty"`
+	// RoleClaim 读取角色的声明, 值为字符串或字符串数组, 取其中最高的角色
+	RoleClaim string `json:"role_claim,omitempty"`
+	// Users 按 email(需 email_verified 为 true) 或 sub 指定用户的角色, 优先于 RoleClaim
+	Users map[string]string `json:"users,omitempty"`
+	// DefaultRole 没有匹配到角色的登录用户的角色
+	DefaultRole string `json:"default_role,omitempty"`
+}
+
+// Auth 页面和接口的认证与角色: viewer 只读, editor 可以触发抓取, admin 可以管理数据源和配置
+type Auth struct {
+	// AnonymousRole 未登录请求的角色, 默认 viewer, 为 none 时所有页面和接口都需要登录
+	AnonymousRole string      `json:"anonymous_role"`
+	Tokens        []APIToken  `json:"tokens,omitempty"`
+	Users         []BasicUser `json:"users,omitempty"`
+	OIDC          OIDC        `json:"oidc"`
+	// SessionSecret 签名登录会话的密钥, 多实例部署时需配置相同的值, 为空时每次启动随机生成
+	SessionSecret string `json:"session_secret,omitempty"`
+	// SessionTTL 登录会话的有效分钟数
+	SessionTTL int `json:"session_ttl"`
+}
+
+// Config 配置文件内容
+type Config struct {
+	Sources    map[string]Source `json:"sources"`
+	Politeness Politeness        `json:"politeness"`
+	Proxy      Proxy             `json:"proxy"`
+	// AdminToken 具有 admin 角色的访问令牌, 等同于 auth.tokens 中的一项
+	AdminToken string    `json:"admin_token,omitempty"`
+	Digest     Digest    `json:"digest"`
+	Storage    Storage   `json:"storage"`
+	Cluster    Cluster   `json:"cluster"`
+	Ranking    Ranking   `json:"ranking"`
+	Dashboard  Dashboard `json:"dashboard"`
+	Auth       Auth      `json:"auth"`
+	// Filter 对全部数据源生效的过滤规则
+	Filter     FilterRule `json:"filter"`
+	Classifier Classifier `json:"classifier"`
+}
+
+var (
+	current   = Default()
+	currentMu sync.RWMutex
+	// path Load 读取的配置文件, SaveSource 写回该文件
+	path   string
+	saveMu sync.Mutex
+)
+
+// Default 默认配置, 配置文件不存在时使用
+func Default() Config {
+	return Config{
+		Sources: map[string]Source{
+			"CrawlerZhiHu": {
+				LandingURL: "https://www.zhihu.com/hot",
+			},
+			"CrawlerWeread": {
+				LandingURL: "https://weread.qq.com/",
+			},
+			"CrawlerDouYin": {
+				LandingURL:   "https://www.douyin.com/",
+				CookieParams: map[string]string{"msToken": "msToken"},
+			},
+		},
+		Politeness: Politeness{
+			Default: HostLimit{
+				RatePerSecond: 1,
+				Burst:         2,
+				MaxConcurrent: 2,
+			},
+			MaxWorkers: 8,
+		},
+		Proxy: Proxy{
+			HealthCheckURL:      "https://www.baidu.com/",
+			HealthCheckInterval: 300,
+		},
+		Digest: Digest{
+			At:   "08:00",
+			TopN: 10,
+		},
+		Storage: Storage{
+			Type:   "file",
+			Prefix: "hot:",
+		},
+		Cluster: Cluster{
+			LeaseTTL: 30,
+		},
+		Ranking: Ranking{
+			Method:   "rank",
+			HalfLife: 360,
+			TopN:     50,
+		},
+		Dashboard: Dashboard{
+			Layout: "tabs",
+		},
+		Auth: Auth{
+			AnonymousRole: "viewer",
+			SessionTTL:    720,
+			OIDC: OIDC{
+				RoleClaim:   "roles",
+				DefaultRole: "viewer",
+			},
+		},
+		Classifier: Classifier{
+			Type:    "keywords",
+			Timeout: 10,
+		},
+	}
+}
+
+// Load 读取配置文件并设为当前配置, 文件不存在时使用默认配置
+func Load(path string) error {
+	cfg, err := LoadFile(path)
+	if err != nil {
+		return err
+	}
+	Set(cfg)
+	setPath(path)
+	return nil
+}
+
+func setPath(p string) {
+	saveMu.Lock()
+	path = p
+	saveMu.Unlock()
+}
+
+// withSource 返回替换了一个数据源配置的当前配置, 只检查这个数据源, 其它配置的问题不影响修改
+func withSource(name string, source Source) (Config, error) {
+	cfg := Get()
+	sources := make(map[string]Source, len(cfg.Sources)+1)
+	for key, value := range cfg.Sources {
+		sources[key] = value
+	}
+	sources[name] = source
+	cfg.Sources = sources
+	for _, err := range Validate(cfg) {
+		if strings.HasPrefix(err.Error(), "sources."+name+".") {
+			return cfg, err
+		}
+	}
+	return cfg, nil
+}
+
+// CheckSource 检查修改后的数据源配置
+func CheckSource(name string, source Source) error {
+	_, err := withSource(name, source)
+	return err
+}
+
+// SetSource 修改一个数据源的配置并设为当前配置, 不写回配置文件, 用于集群中保存在共享存储的修改
+func SetSource(name string, source Source) error {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	cfg, err := withSource(name, source)
+	if err != nil {
+		return err
+	}
+	Set(cfg)
+	return nil
+}
+
+// SaveSource 修改一个数据源的配置, 写回配置文件后设为当前配置, 文件中的其它配置保持原样
+func SaveSource(name string, source Source) error {
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	if path == "" {
+		return errors.New("no config file loaded")
+	}
+	cfg, err := withSource(name, source)
+	if err != nil {
+		return err
+	}
+
+	raw := map[string]json.RawMessage{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	rawSources := map[string]json.RawMessage{}
+	if value, ok := raw["sources"]; ok {
+		if err := json.Unmarshal(value, &rawSources); err != nil {
+			return fmt.Errorf("parse %s: sources: %w", path, err)
+		}
+	}
+	rawSources[name], err = json.Marshal(source)
+	if err != nil {
+		return err
+	}
+	raw["sources"], err = json.Marshal(rawSources)
+	if err != nil {
+		return err
+	}
+	data, err = json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	Set(cfg)
+	return nil
+}
+
+// LoadFile 读取配置文件, 文件中出现的字段覆盖默认配置, 数据源和站点按名称整项覆盖
+func LoadFile(path string) (Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate 检查配置中的取值, 返回发现的全部问题
+func Validate(cfg Config) []error {
+	var errs []error
+	checkURL := func(field, raw string, schemes ...string) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+			return
+		}
+		for _, scheme := range schemes {
+			if u.Scheme == scheme {
+				return
+			}
+		}
+		errs = append(errs, fmt.Errorf("%s: unsupported scheme %q in %q", field, u.Scheme, raw))
+	}
+	checkLimit := func(field string, limit HostLimit) {
+		if limit.RatePerSecond < 0 || limit.Burst < 0 || limit.MaxConcurrent < 0 {
+			errs = append(errs, fmt.Errorf("%s: values must not be negative", field))
+		}
+	}
+	for name, source := range cfg.Sources {
+		if source.LandingURL != "" {
+			checkURL("sources."+name+".landing_url", source.LandingURL, "http", "https")
+		} else if len(source.Cookies) > 0 {
+			errs = append(errs, fmt.Errorf("sources.%s.cookies: landing_url is required to scope cookies", name))
+		}
+		if len(source.Proxies) == 1 && source.Proxies[0] == "direct" {
+			continue
+		}
+		for _, proxy := range source.Proxies {
+			checkURL("sources."+name+".proxies", proxy, "http", "https", "socks5")
+		}
+	}
+	checkFilter := func(field string, rule FilterRule) {
+		for _, pattern := range rule.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				errs = append(errs, fmt.Errorf("%s.patterns: %w", field, err))
+			}
+		}
+		for _, domain := range rule.Domains {
+			if strings.TrimSpace(domain) == "" || strings.Contains(domain, "/") {
+				errs = append(errs, fmt.Errorf("%s.domains: %q is not a domain", field, domain))
+			}
+		}
+		for _, position := range rule.Positions {
+			if position < 1 {
+				errs = append(errs, fmt.Errorf("%s.positions: positions start at 1", field))
+			}
+		}
+	}
+	checkFilter("filter", cfg.Filter)
+	for name, source := range cfg.Sources {
+		if source.Filter != nil {
+			checkFilter("sources."+name+".filter", *source.Filter)
+		}
+		if source.URL != "" {
+			checkURL("sources."+name+".url", source.URL, "http", "https")
+		}
+		if source.Interval < 0 {
+			errs = append(errs, fmt.Errorf("sources.%s.interval: must not be negative", name))
+		}
+	}
+	checkLimit("politeness.default", cfg.Politeness.Default)
+	for host, limit := range cfg.Politeness.Hosts {
+		checkLimit("politeness.hosts."+host, limit)
+	}
+	if cfg.Politeness.MaxWorkers < 0 {
+		errs = append(errs, fmt.Errorf("politeness.max_workers: must not be negative"))
+	}
+	for _, proxy := range cfg.Proxy.URLs {
+		checkURL("proxy.urls", proxy, "http", "https", "socks5")
+	}
+	if cfg.Proxy.HealthCheckURL != "" {
+		checkURL("proxy.health_check_url", cfg.Proxy.HealthCheckURL, "http", "https")
+	}
+	if _, err := time.Parse("15:04", cfg.Digest.At); err != nil {
+		errs = append(errs, fmt.Errorf("digest.at: %q is not HH:MM", cfg.Digest.At))
+	}
+	for i, notifier := range cfg.Digest.Notifiers {
+		field := fmt.Sprintf("digest.notifiers[%d]", i)
+		switch notifier.Type {
+		case "file":
+			if notifier.Dir == "" {
+				errs = append(errs, fmt.Errorf("%s: dir is required", field))
+			}
+		case "webhook":
+			checkURL(field+".url", notifier.URL, "http", "https")
+		case "smtp":
+			if notifier.Addr == "" || notifier.From == "" || len(notifier.To) == 0 {
+				errs = append(errs, fmt.Errorf("%s: addr, from and to are required", field))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("%s: unknown type %q", field, notifier.Type))
+		}
+	}
+	switch cfg.Storage.Type {
+	case "file":
+	case "sqlite", "postgres":
+		if cfg.Storage.DSN == "" {
+			errs = append(errs, fmt.Errorf("storage.dsn: required for %s", cfg.Storage.Type))
+		}
+	case "redis":
+		checkURL("storage.dsn", cfg.Storage.DSN, "redis", "rediss")
+	default:
+		errs = append(errs, fmt.Errorf("storage.type: unknown type %q", cfg.Storage.Type))
+	}
+	if cfg.Ranking.Method != "rank" && cfg.Ranking.Method != "zscore" {
+		errs = append(errs, fmt.Errorf("ranking.method: unknown method %q, use rank or zscore", cfg.Ranking.Method))
+	}
+	for name, weight := range cfg.Ranking.Weights {
+		if weight < 0 {
+			errs = append(errs, fmt.Errorf("ranking.weights.%s: must not be negative", name))
+		}
+	}
+	if cfg.Ranking.HalfLife < 0 || cfg.Ranking.TopN < 0 {
+		errs = append(errs, fmt.Errorf("ranking: half_life and top_n must not be negative"))
+	}
+	if cfg.Dashboard.Layout != "tabs" && cfg.Dashboard.Layout != "grid" {
+		errs = append(errs, fmt.Errorf("dashboard.layout: unknown layout %q, use tabs or grid", cfg.Dashboard.Layout))
+	}
+	if cfg.Cluster.Enabled && cfg.Cluster.LeaseTTL < 3 {
+		errs = append(errs, fmt.Errorf("cluster.lease_ttl: must be at least 3 seconds"))
+	}
+	checkRole := func(field, role string) {
+		switch role {
+		case "viewer", "editor", "admin":
+		default:
+			errs = append(errs, fmt.Errorf("%s: unknown role %q, use viewer, editor or admin", field, role))
+		}
+	}
+	if cfg.Auth.AnonymousRole != "none" {
+		checkRole("auth.anonymous_role", cfg.Auth.AnonymousRole)
+	}
+	for i, token := range cfg.Auth.Tokens {
+		field := fmt.Sprintf("auth.tokens[%d]", i)
+		if token.Token == "" {
+			errs = append(errs, fmt.Errorf("%s.token: required", field))
+		}
+		checkRole(field+".role", token.Role)
+	}
+	for i, user := range cfg.Auth.Users {
+		field := fmt.Sprintf("auth.users[%d]", i)
+		if user.Username == "" || user.Password == "" {
+			errs = append(errs, fmt.Errorf("%s: username and password are required", field))
+		}
+		checkRole(field+".role", user.Role)
+	}
+	if oidc := cfg.Auth.OIDC; oidc.Issuer != "" {
+		checkURL("auth.oidc.issuer", oidc.Issuer, "http", "https")
+		checkURL("auth.oidc.redirect_url", oidc.RedirectURL, "http", "https")
+		if oidc.ClientID == "" {
+			errs = append(errs, fmt.Errorf("auth.oidc.client_id: required"))
+		}
+		checkRole("auth.oidc.default_role", oidc.DefaultRole)
+		for user, role := range oidc.Users {
+			checkRole("auth.oidc.users."+user, role)
+		}
+	}
+	if cfg.Auth.SessionTTL <= 0 {
+		errs = append(errs, fmt.Errorf("auth.session_ttl: must be positive"))
+	}
+	switch classifier := cfg.Classifier; classifier.Type {
+	case "keywords", "none":
+	case "http", "command":
+		if classifier.Type == "http" {
+			checkURL("classifier.url", classifier.URL, "http", "https")
+		} else if len(classifier.Command) == 0 || classifier.Command[0] == "" {
+			errs = append(errs, fmt.Errorf("classifier.command: required for command"))
+		}
+		// 超时为 0 时 http.Client 不会超时, 模型服务卡住会拖住整轮抓取
+		if classifier.Timeout <= 0 {
+			errs = append(errs, fmt.Errorf("classifier.timeout: must be positive for %s", classifier.Type))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("classifier.type: unknown type %q, use keywords, http, command or none", classifier.Type))
+	}
+	return errs
+}
+
+// HostLimitOf 返回站点的限速配置, 未单独配置时返回默认值
+func HostLimitOf(host string) HostLimit {
+	politeness := Get().Politeness
+	if limit, ok := politeness.Hosts[host]; ok {
+		return limit
+	}
+	return politeness.Default
+}
+
+// Get 返回当前配置
+func Get() Config {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// Set 替换当前配置
+func Set(cfg Config) {
+	currentMu.Lock()
+	current = cfg
+	currentMu.Unlock()
+}
+
+// ProxiesOf 返回数据源使用的代理地址, 为空表示直连
+func ProxiesOf(name string) []string {
+	cfg := Get()
+	proxies := cfg.Sources[name].Proxies
+	if len(proxies) == 0 {
+		proxies = cfg.Proxy.URLs
+	}
+	if len(proxies) == 1 && proxies[0] == "direct" {
+		return nil
+	}
+	return proxies
+}
+
+// SourceOf 返回指定数据源的配置, 未配置时返回零值
+func SourceOf(name string) Source {
+	return Get().Sources[name]
+}
@@ -0,0 +1,123 @@
+package cralwer
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Classifier 给条目打上分类标签并标记敏感内容
+type Classifier interface {
+	// Classify 按 items 的顺序返回每个条目的标签, source 为数据源 ID
+	Classify(source string, items []ClassifyItem) ([]Label, error)
+}
+
+// ClassifyItem 交给分类器的条目
+type ClassifyItem struct {
+	Title string `json:"title"`
+	Href  string `json:"href,omitempty"`
+}
+
+// Label 一个条目的分类结果
+type Label struct {
+	// Categories 条目所属的分类, 如 politics、tech, 可以有多个或没有
+	Categories []string `json:"categories,omitempty"`
+	// Sensitive 是否为敏感内容, 页面默认隐藏
+	Sensitive bool `json:"sensitive,omitempty"`
+}
+
+var (
+	classifier   Classifier
+	classifierMu sync.RWMutex
+)
+
+// SetClassifier 替换使用的分类器, 为 nil 时不分类
+func SetClassifier(c Classifier) {
+	classifierMu.Lock()
+	classifier = c
+	classifierMu.Unlock()
+}
+
+func currentClassifier() Classifier {
+	classifierMu.RLock()
+	defer classifierMu.RUnlock()
+	return classifier
+}
+
+// classify 把分类结果写入条目的 categories 和 sensitive 字段, 分类失败时条目保持原样
+func (c Crawler) classify(content []map[string]interface{}) {
+	classifier := currentClassifier()
+	if classifier == nil || len(content) == 0 {
+		return
+	}
+	items := make([]ClassifyItem, len(content))
+	for i, item := range content {
+		items[i].Title = fmt.Sprint(item["title"])
+		items[i].Href, _ = item["href"].(string)
+	}
+	labels, err := classifier.Classify(c.crawlerName, items)
+	if err != nil {
+		Logln(c.crawlerName, "classify err:", err)
+		return
+	}
+	for i, item := range content {
+		if i >= len(labels) {
+			break
+		}
+		if len(labels[i].Categories) > 0 {
+			item["categories"] = labels[i].Categories
+		}
+		if labels[i].Sensitive {
+			item["sensitive"] = true
+		}
+	}
+}
+
+// ItemCategories 返回条目的分类, 从存储读出的条目中为 []interface{}
+func ItemCategories(item map[string]interface{}) []string {
+	switch value := item["categories"].(type) {
+	case []string:
+		return value
+	case []interface{}:
+		categories := make([]string, 0, len(value))
+		for _, category := range value {
+			if s, ok := category.(string); ok {
+				categories = append(categories, s)
+			}
+		}
+		return categories
+	}
+	return nil
+}
+
+// ItemSensitive 条目是否被标记为敏感内容
+func ItemSensitive(item map[string]interface{}) bool {
+	sensitive, _ := item["sensitive"].(bool)
+	return sensitive
+}
+
+// FilterCategory 返回属于 category 的条目, category 为空时不按分类筛选, hideSensitive 为 true 时去掉敏感内容
+func FilterCategory(content []map[string]interface{}, category string, hideSensitive bool) []map[string]interface{} {
+	if category == "" && !hideSensitive {
+		return content
+	}
+	kept := make([]map[string]interface{}, 0, len(content))
+	for _, item := range content {
+		if hideSensitive && ItemSensitive(item) {
+			continue
+		}
+		if category != "" && !hasCategory(item, category) {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+func hasCategory(item map[string]interface{}, category string) bool {
+	for _, c := range ItemCategories(item) {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
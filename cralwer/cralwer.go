@@ -2,75 +2,103 @@ package cralwer
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"github.com/PuerkitoBio/goquery"
 	"github.com/bitly/go-simplejson"
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/transform"
-	"io"
-	"log"
+	"goCrawlerHot/config"
+	"goCrawlerHot/i18n"
 	"net/http"
-	"os"
-	"path/filepath"
-	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
 type Result struct {
+	// HotName 数据源的默认语言名称, 由 ExecGetData 按 Source 填写
 	HotName     string                   `json:"hot_name"`
 	Content     []map[string]interface{} `json:"content"`
 	CrawlerTime string                   `json:"crawler_time"`
+	// Source 爬虫名称, 由 ExecGetData 填写
+	Source string `json:"source"`
+	// Filtered 被过滤规则过滤掉的条数, 由 ExecGetData 填写
+	Filtered int `json:"filtered,omitempty"`
+}
+
+// ItemText 返回条目字段的字符串值, 字段不存在时返回空字符串, 热度等较大的数字按普通小数输出, 避免科学计数法
+func ItemText(item map[string]interface{}, key string) string {
+	value, ok := item[key]
+	if !ok || value == nil {
+		return ""
+	}
+	if number, ok := value.(float64); ok {
+		return strconv.FormatFloat(number, 'f', -1, 64)
+	}
+	return strings.TrimSpace(fmt.Sprint(value))
+}
+
+// EscapeMarkdown 转义 Markdown 表格单元格中的竖线和换行
+func EscapeMarkdown(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\r", "")
+	return strings.ReplaceAll(s, "\n", " ")
 }
 
 type Crawler struct {
 	crawlerName string
+	// draft 测试抓取时使用的数据源配置, 为 nil 时使用当前配置
+	draft *config.Source
+	// capture 测试抓取时记录原始响应
+	capture *capture
+	// ctx 取消时中止正在进行的请求, 为 nil 时不取消
+	ctx context.Context
+}
+
+// requestContext 爬虫发出的请求使用的 ctx
+func (c Crawler) requestContext() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
 }
 
 // CrawlerWeiBo 爬取微博热榜信息
 func (c Crawler) CrawlerWeiBo() (Result, error) {
 	var content []map[string]interface{}
 	timeout := 10 * time.Second
-	client := &http.Client{
-		Timeout: timeout,
-	}
-	mUrl := "https://m.weibo.cn/api/container/getIndex?containerid=106003type%3D25%26t%3D3%26disable_hot%3D1%26filter_type%3Drealtimehot&title=%E5%BE%AE%E5%8D%9A%E7%83%AD%E6%90%9C&extparam=seat%3D1%26pos%3D0_0%26dgr%3D0%26mi_cid%3D100103%26cate%3D10103%26filter_type%3Drealtimehot%26c_type%3D30%26display_time%3D1638445376%26pre_seqid%3D52252862&luicode=10000011&lfid=231583"
-	req, err := http.NewRequest("GET", mUrl, nil)
-	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
+	client := c.newClient(timeout)
+	mUrl := c.endpoint()
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", mUrl, nil)
 	if err != nil {
-		fmt.Println("CrawlerWeiBo http.NewRequest err:", err)
-		return Result{HotName: "新浪微博"}, err
+		Logln("CrawlerWeiBo http.NewRequest err:", err)
+		return Result{}, err
 	}
-	res, err := client.Do(req)
+	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
+	str, err := fetch(client, req)
 	if err != nil {
-		fmt.Println("client.Do err:", err)
-		return Result{HotName: "新浪微博"}, err
-	}
-	if res.StatusCode != 200 {
-		log.Printf(" CrawlerWeiBo status code error: %d %s", res.StatusCode, res.Status)
-		return Result{HotName: "新浪微博"}, err
+		Logln("CrawlerWeiBo fetch err:", err)
+		return Result{}, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("CrawlerWeiBo close err:", err)
-		}
-	}(res.Body)
-	str, _ := io.ReadAll(res.Body)
 	j, err := simplejson.NewJson(str)
 	if err != nil {
-		fmt.Println(" simplejson.NewJson err:", err)
-		return Result{HotName: "新浪微博"}, err
+		Logln(" simplejson.NewJson err:", err)
+		return Result{}, err
 	}
 	cardGroup := j.Get("data").Get("cards").GetIndex(0).Get("card_group").MustArray()
 	for _, val := range cardGroup {
-		title := val.(map[string]interface{})["desc"]
+		card, ok := val.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		title := card["desc"]
 		href := fmt.Sprintf("https://s.weibo.com/weibo?q=%%23%s%%23", title)
-		content = append(content, map[string]interface{}{"title": title, "href": href})
+		item := map[string]interface{}{"title": title, "href": href}
+		// desc_extr 为热度, 部分条目带分类前缀, 如 "剧集 123456"
+		setHeat(item, card["desc_extr"])
+		content = append(content, item)
 	}
-	result := Result{"新浪微博", content, time.Now().Format("2006-01-02 15:04:05")}
+	result := Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}
 
 	return result, nil
 }
@@ -78,36 +106,27 @@ func (c Crawler) CrawlerWeiBo() (Result, error) {
 // CrawlerZhiHu 爬取知乎热榜信息
 func (c Crawler) CrawlerZhiHu() (Result, error) {
 	var content []map[string]interface{}
-	url := "https://www.zhihu.com/api/v3/feed/topstory/hot-lists/total?limit=50&desktop=true"
+	url := c.endpoint()
 	timeout := 5 * time.Second
-	client := &http.Client{
-		Timeout: timeout,
-	}
-	req, err := http.NewRequest("GET", url, nil)
+	client := c.newClient(timeout)
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
 	if err != nil {
-		fmt.Println("CrawlerZhiHu http.NewRequest err:", err)
-		return Result{HotName: "知乎热榜"}, err
+		Logln("CrawlerZhiHu http.NewRequest err:", err)
+		return Result{}, err
 	}
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Add("path", "/api/v3/feed/topstory/hot-lists/total?limit=50&desktop=true")
 	req.Header.Add("x-api-version", "3.0.76")
 	req.Header.Add("x-requested-with", "fetch")
-	res, err := client.Do(req)
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("err", err)
-		}
-	}(res.Body)
+	body, err := fetch(client, req)
 	if err != nil {
-		fmt.Println("CrawlerZhiHu client.Do err:", err)
-		return Result{HotName: "知乎热榜"}, err
+		Logln("CrawlerZhiHu fetch err:", err)
+		return Result{}, err
 	}
-	body, _ := io.ReadAll(res.Body)
 	j, err := simplejson.NewJson(body)
 	if err != nil {
-		fmt.Println("")
-		return Result{HotName: "知乎热榜"}, err
+		Logln("CrawlerZhiHu simplejson.NewJson err:", err)
+		return Result{}, err
 	}
 	dataJson := j.Get("data")
 	dataArr := j.Get("data").MustArray()
@@ -115,408 +134,318 @@ func (c Crawler) CrawlerZhiHu() (Result, error) {
 		info := dataJson.GetIndex(index)
 		title := info.Get("target").Get("title_area").Get("text").MustString()
 		href := info.Get("target").Get("link").Get("url").MustString()
-		content = append(content, map[string]interface{}{"title": title, "href": href})
+		item := map[string]interface{}{"title": title, "href": href}
+		// detail_text 如 "1234 万热度"
+		setHeat(item, info.Get("detail_text").MustString())
+		content = append(content, item)
 	}
 
-	return Result{"知乎热榜", content, time.Now().Format("2006-01-02 15:04:05")}, nil
+	return Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}, nil
 }
 
 // CrawlerTieBa 爬取贴吧热榜
 func (c Crawler) CrawlerTieBa() (Result, error) {
 	var content []map[string]interface{}
-	url := "https://tieba.baidu.com/hottopic/browse/topicList"
+	url := c.endpoint()
 	timeout := time.Second * 10
-	client := &http.Client{
-		Timeout: timeout,
-	}
-	req, err := http.NewRequest("GET", url, nil)
+	client := c.newClient(timeout)
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
 	if err != nil {
-		fmt.Println("CrawlerTieBa http.NewRequest err:", err)
-		return Result{HotName: "贴吧"}, err
+		Logln("CrawlerTieBa http.NewRequest err:", err)
+		return Result{}, err
 	}
-	res, err := client.Do(req)
+	str, err := fetch(client, req)
 	if err != nil {
-		fmt.Println("CrawlerTieBa client.Do err:", err)
-		return Result{HotName: "贴吧"}, err
+		Logln("CrawlerTieBa fetch err:", err)
+		return Result{}, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("err:", err)
-		}
-	}(res.Body)
-
-	str, _ := io.ReadAll(res.Body)
 	j, err := simplejson.NewJson(str)
 	if err != nil {
-		fmt.Println("CrawlerTieBa simplejson.NewJson err:", err)
-		return Result{HotName: "贴吧"}, err
+		Logln("CrawlerTieBa simplejson.NewJson err:", err)
+		return Result{}, err
 	}
 	topicList := j.Get("data").Get("bang_topic").Get("topic_list")
 	topicArr := topicList.MustArray()
 	for index := range topicArr {
 		title := topicList.GetIndex(index).Get("topic_name").MustString()
 		href := topicList.GetIndex(index).Get("topic_url").MustString()
-		content = append(content, map[string]interface{}{"title": title, "href": href})
+		item := map[string]interface{}{"title": title, "href": href}
+		// discuss_num 为实时讨论数
+		setHeat(item, topicList.GetIndex(index).Get("discuss_num").Interface())
+		content = append(content, item)
 	}
-	return Result{"贴吧", content, time.Now().Format("2006-01-02 15:04:05")}, nil
+	return Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}, nil
 
 }
 
 // CrawlerDouBan 爬取豆瓣热榜
 func (c Crawler) CrawlerDouBan() (Result, error) {
 	var content []map[string]interface{}
-	url := "https://www.douban.com/group/explore"
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
-	req, err := http.NewRequest("GET", url, nil)
+	url := c.endpoint()
+	client := c.newClient(time.Second * 10)
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
 	if err != nil {
-		fmt.Println("CrawlerDouBan http.NewRequest err:", err)
-		return Result{HotName: "豆瓣热榜"}, err
+		Logln("CrawlerDouBan http.NewRequest err:", err)
+		return Result{}, err
 	}
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Add("Upgrade-Insecure-Requests", "1")
 	req.Header.Add("Host", "www.douban.com")
-	res, err := client.Do(req)
+	body, err := fetch(client, req)
 	if err != nil {
-		fmt.Println("CrawlerDouBan client.Do err:", err)
-		return Result{HotName: "豆瓣热榜"}, err
+		Logln("CrawlerDouBan fetch err:", err)
+		return Result{}, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("err:", err)
-		}
-	}(res.Body)
-	if res.StatusCode != 200 {
-		log.Printf("CrawlerDouBan status code error: %d %s", res.StatusCode, res.Status)
-		return Result{HotName: "豆瓣热榜"}, err
-	}
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		fmt.Println("CrawlerDouBan goquery.NewDocumentFromReader err:", err)
-		return Result{HotName: "豆瓣热榜"}, err
+		Logln("CrawlerDouBan goquery.NewDocumentFromReader err:", err)
+		return Result{}, err
 	}
-	doc.Find(".channel-item").Each(func(i int, s *goquery.Selection) {
-		title := s.Find("h3 a").Text()
-		href, boolHref := s.Find("h3 a").Attr("href")
+	doc.Find(c.selector("item")).Each(func(i int, s *goquery.Selection) {
+		title := s.Find(c.selector("title")).Text()
+		href, boolHref := s.Find(c.selector("title")).Attr("href")
 		if boolHref {
 			content = append(content, map[string]interface{}{"title": title, "href": href})
 		}
 
 	})
-	return Result{"豆瓣热榜", content, time.Now().Format("2006-01-02 15:04:05")}, nil
+	return Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}, nil
 }
 
 // CrawlerTianYa 爬取天涯热榜
 func (c Crawler) CrawlerTianYa() (Result, error) {
 	var content []map[string]interface{}
-	url := "http://bbs.tianya.cn/hotArticle.jsp"
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
-	req, err := http.NewRequest("GET", url, nil)
+	url := c.endpoint()
+	client := c.newClient(time.Second * 10)
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
 	if err != nil {
-		fmt.Println("CrawlerTianYa http.NewRequest err:", err)
-		return Result{HotName: "天涯热榜"}, err
+		Logln("CrawlerTianYa http.NewRequest err:", err)
+		return Result{}, err
 	}
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Add("Host", "bbs.tianya.cn")
-	res, err := client.Do(req)
+	body, err := fetch(client, req)
 	if err != nil {
-		fmt.Println("CrawlerTianYa client.Do err:", err)
-		return Result{HotName: "天涯热榜"}, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("err:", err)
-		}
-	}(res.Body)
-	if res.StatusCode != 200 {
-		log.Printf("CrawlerTianYa status code error: %d %s", res.StatusCode, res.Status)
-		return Result{HotName: "天涯热榜"}, err
+		Logln("CrawlerTianYa fetch err:", err)
+		return Result{}, err
 	}
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		fmt.Println("CrawlerTianYa goquery.NewDocumentFromReader err:", err)
-		return Result{HotName: "天涯热榜"}, err
+		Logln("CrawlerTianYa goquery.NewDocumentFromReader err:", err)
+		return Result{}, err
 	}
-	doc.Find(".mt5 table tbody tr").Slice(1, -1).Each(func(i int, selection *goquery.Selection) {
-		title := selection.Find("td[class=td-title] a").Text()
-		href := "http://bbs.tianya.cn" + selection.Find("td[class=td-title] a").AttrOr("href", "")
+	doc.Find(c.selector("item")).Slice(1, -1).Each(func(i int, selection *goquery.Selection) {
+		title := selection.Find(c.selector("title")).Text()
+		href := "http://bbs.tianya.cn" + selection.Find(c.selector("title")).AttrOr("href", "")
 		content = append(content, map[string]interface{}{"title": title, "href": href})
 	})
 
-	return Result{"天涯热榜", content, time.Now().Format("2006-01-02 15:04:05")}, nil
+	return Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}, nil
 
 }
 
 // CrawlerGithub 爬取github trending
 func (c Crawler) CrawlerGithub() (Result, error) {
 	var content []map[string]interface{}
-	url := "https://github.com/trending"
-	client := &http.Client{
-		Timeout: time.Second * 20,
-	}
-	req, err := http.NewRequest("GET", url, nil)
+	url := c.endpoint()
+	client := c.newClient(time.Second * 20)
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
 	if err != nil {
-		fmt.Println("CrawlerDouBan http.NewRequest err:", err)
-		return Result{HotName: "GitHub Trending"}, err
+		Logln("CrawlerGithub http.NewRequest err:", err)
+		return Result{}, err
 	}
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Add("Referer", "https://github.com/explore")
 	req.Header.Add("Host", "github.com")
-	res, err := client.Do(req)
+	body, err := fetch(client, req)
 	if err != nil {
-		fmt.Println("CrawlerDouBan client.Do err:", err)
-		return Result{HotName: "GitHub Trending"}, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("err:", err)
-		}
-	}(res.Body)
-	if res.StatusCode != 200 {
-		log.Printf("CrawlerDouBan status code error: %d %s", res.StatusCode, res.Status)
-		return Result{HotName: "GitHub Trending"}, err
+		Logln("CrawlerGithub fetch err:", err)
+		return Result{}, err
 	}
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		fmt.Println("CrawlerDouBan goquery.NewDocumentFromReader err:", err)
-		return Result{HotName: "GitHub Trending"}, err
-	}
-	doc.Find("article[class=Box-row]").Each(func(i int, selection *goquery.Selection) {
-		title := strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(selection.Find("h2 a").Text()), "\n", ""), " ", "")
-		href := "https://github.com/" + selection.Find("h2 a").AttrOr("href", "")
-		describe := strings.TrimSpace(selection.Find("p").Text())
-		content = append(content, map[string]interface{}{"title": title + "<---->" + describe, "href": href})
-
+		Logln("CrawlerGithub goquery.NewDocumentFromReader err:", err)
+		return Result{}, err
+	}
+	doc.Find(c.selector("item")).Each(func(i int, selection *goquery.Selection) {
+		title := strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(selection.Find(c.selector("title")).Text()), "\n", ""), " ", "")
+		href := "https://github.com/" + selection.Find(c.selector("title")).AttrOr("href", "")
+		describe := strings.TrimSpace(selection.Find(c.selector("description")).Text())
+		item := map[string]interface{}{"title": title + "<---->" + describe, "href": href}
+		// 今日新增 star, 如 "1,234 stars today"
+		setHeat(item, strings.TrimSpace(selection.Find(c.selector("heat")).Text()))
+		content = append(content, item)
 	})
-	return Result{"GitHub Trending", content, time.Now().Format("2006-01-02 15:04:05")}, nil
+	return Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}, nil
 }
 
 // CrawlerWangYiYun 获取网易云音乐
 func (c Crawler) CrawlerWangYiYun() (Result, error) {
 	var content []map[string]interface{}
-	url := "https://music.163.com/discover/toplist?id=19723756"
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
-	req, err := http.NewRequest("GET", url, nil)
+	url := c.endpoint()
+	client := c.newClient(time.Second * 10)
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
 	if err != nil {
-		fmt.Println("CrawlerWangYiYun http.NewRequest err:", err)
-		return Result{HotName: "云音乐飙升榜"}, err
+		Logln("CrawlerWangYiYun http.NewRequest err:", err)
+		return Result{}, err
 	}
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
 	req.Header.Add("authority", "music.163.com")
 	req.Header.Add("Referer", "https://music.163.com/")
-	res, err := client.Do(req)
+	body, err := fetch(client, req)
 	if err != nil {
-		fmt.Println("CrawlerWangYiYun client.Do err:", err)
-		return Result{HotName: "云音乐飙升榜"}, err
+		Logln("CrawlerWangYiYun fetch err:", err)
+		return Result{}, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("err:", err)
-		}
-	}(res.Body)
-	if res.StatusCode != 200 {
-		log.Printf("CrawlerWangYiYun status code error: %d %s", res.StatusCode, res.Status)
-		return Result{HotName: "云音乐飙升榜"}, err
-	}
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		fmt.Println("CrawlerWangYiYun goquery.NewDocumentFromReader err:", err)
-		return Result{HotName: "云音乐飙升榜"}, err
+		Logln("CrawlerWangYiYun goquery.NewDocumentFromReader err:", err)
+		return Result{}, err
 	}
 
-	doc.Find("div[id=song-list-pre-cache] ul[class=f-hide] li").Each(func(i int, selection *goquery.Selection) {
-		title := selection.Find("a").Text()
-		href := "https://music.163.com/#%s" + selection.Find("a").AttrOr("href", "")
+	doc.Find(c.selector("item")).Each(func(i int, selection *goquery.Selection) {
+		title := selection.Find(c.selector("title")).Text()
+		href := "https://music.163.com/#%s" + selection.Find(c.selector("title")).AttrOr("href", "")
 		content = append(content, map[string]interface{}{"title": title, "href": href})
 
 	})
-	return Result{"云音乐飙升榜", content, time.Now().Format("2006-01-02 15:04:05")}, nil
+	return Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}, nil
 }
 
+// CrawlerCSDN 爬取CSDN热榜, 共4页, 每页25条
 func (c Crawler) CrawlerCSDN() (Result, error) {
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
-	pages := [4]string{"0", "1", "2", "3"}
-	var content []map[string]interface{}
-	for _, page := range pages {
-		url := "https://blog.csdn.net/phoenix/web/blog/hot-rank?page=" + page + "&pageSize=25&type="
-		req, err := http.NewRequest("GET", url, nil)
+	client := c.newClient(time.Second * 10)
+	paginator := Paginator{
+		Strategy: PageNumber,
+		URL: func(page string) string {
+			return strings.ReplaceAll(c.endpoint(), "{page}", page)
+		},
+		MaxPages:    4,
+		StopOnEmpty: true,
+		Concurrency: 4,
+	}
+	content, err := paginator.Run(func(url string) (Page, error) {
+		var page Page
+		req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
 		if err != nil {
-			fmt.Println("CrawlerCSDN http.NewRequest err:", err)
-			continue
+			Logln("CrawlerCSDN http.NewRequest err:", err)
+			return page, err
 		}
 		req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
-		res, err := client.Do(req)
+		str, err := fetch(client, req)
 		if err != nil {
-			fmt.Println("CrawlerCSDN client.Do err:", err)
-			continue
+			Logln("CrawlerCSDN fetch err:", err)
+			return page, err
 		}
-		str, _ := io.ReadAll(res.Body)
 		j, err := simplejson.NewJson(str)
 		if err != nil {
-			fmt.Println("CrawlerCSDN simplejson.NewJson err:", err)
-			continue
+			Logln("CrawlerCSDN simplejson.NewJson err:", err)
+			return page, err
 		}
-		func(Body io.ReadCloser) {
-			err := Body.Close()
-			if err != nil {
-				fmt.Println("err:", err)
-			}
-		}(res.Body)
 		dataJson := j.Get("data")
 		dataArr := j.Get("data").MustArray()
 		for index := range dataArr {
 			info := dataJson.GetIndex(index)
 			title := info.Get("articleTitle").MustString()
 			href := info.Get("articleDetailUrl").MustString()
-			content = append(content, map[string]interface{}{"title": title, "href": href})
+			item := map[string]interface{}{"title": title, "href": href}
+			setHeat(item, info.Get("hotRankScore").Interface())
+			page.Items = append(page.Items, item)
 		}
-	}
+		return page, nil
+	})
 
-	return Result{"CSDN热榜", content, time.Now().Format("2006-01-02 15:04:05")}, nil
+	return Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}, err
 }
 
-//CrawlerWeread 获取微信读书热榜
+// CrawlerWeread 获取微信读书热榜
 func (c Crawler) CrawlerWeread() (Result, error) {
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
+	client := c.newClient(time.Second * 10)
 	var content []map[string]interface{}
 
-	url := "https://weread.qq.com/web/category/rising"
-	req, err := http.NewRequest("GET", url, nil)
+	url := c.endpoint()
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
 	if err != nil {
-		fmt.Println("CrawlerWeread http.NewRequest err:", err)
-		return Result{HotName: "微信读书飙升榜"}, err
+		Logln("CrawlerWeread http.NewRequest err:", err)
+		return Result{}, err
 	}
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
-	res, err := client.Do(req)
+	body, err := fetch(client, req)
 	if err != nil {
-		fmt.Println("CrawlerWangYiYun client.Do err:", err)
-		return Result{HotName: "微信读书飙升榜"}, err
+		Logln("CrawlerWeread fetch err:", err)
+		return Result{}, err
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("err:", err)
-		}
-	}(res.Body)
-	if res.StatusCode != 200 {
-		log.Printf("CrawlerWangYiYun status code error: %d %s", res.StatusCode, res.Status)
-		return Result{HotName: "微信读书飙升榜"}, err
-	}
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		fmt.Println("CrawlerWangYiYun goquery.NewDocumentFromReader err:", err)
-		return Result{HotName: "微信读书飙升榜"}, err
+		Logln("CrawlerWeread goquery.NewDocumentFromReader err:", err)
+		return Result{}, err
 	}
-	doc.Find(".ranking_content_bookList li[class=wr_bookList_item]").Each(func(i int, selection *goquery.Selection) {
-		title := selection.Find("p[class=wr_bookList_item_title]").Text()
-		href := "https://weread.qq.com" + selection.Find("a[class=wr_bookList_item_link]").AttrOr("href", "")
+	doc.Find(c.selector("item")).Each(func(i int, selection *goquery.Selection) {
+		title := selection.Find(c.selector("title")).Text()
+		href := "https://weread.qq.com" + selection.Find(c.selector("link")).AttrOr("href", "")
 		content = append(content, map[string]interface{}{"title": title, "href": href})
 	})
 
-	return Result{"微信读书飙升榜", content, time.Now().Format("2006-01-02 15:04:05")}, nil
+	return Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}, nil
 }
 
 // Crawler52PoJie 吾爱破解
 func (c Crawler) Crawler52PoJie() (Result, error) {
-	client := &http.Client{
-		Timeout: time.Second * 10,
-	}
+	client := c.newClient(time.Second * 10)
 	var content []map[string]interface{}
 
-	url := "https://www.52pojie.cn/forum.php?mod=guide&view=hot"
-	req, err := http.NewRequest("GET", url, nil)
+	url := c.endpoint()
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
 	if err != nil {
-		fmt.Println("Crawler52PoJie http.NewRequest err:", err)
-		return Result{HotName: "吾爱破解"}, err
+		Logln("Crawler52PoJie http.NewRequest err:", err)
+		return Result{}, err
 	}
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36")
-	res, err := client.Do(req)
+	// 页面为 GBK 编码, fetch 已经转换为 UTF-8
+	body, err := fetch(client, req)
 	if err != nil {
-		fmt.Println("Crawler52PoJie client.Do err:", err)
-		return Result{HotName: "吾爱破解"}, err
-	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("err:", err)
-		}
-	}(res.Body)
-	if res.StatusCode != 200 {
-		log.Printf("Crawler52PoJie status code error: %d %s", res.StatusCode, res.Status)
-		return Result{HotName: "吾爱破解"}, err
+		Logln("Crawler52PoJie fetch err:", err)
+		return Result{}, err
 	}
-	doc, err := goquery.NewDocumentFromReader(res.Body)
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
-		fmt.Println("Crawler52PoJie goquery.NewDocumentFromReader err:", err)
-		return Result{HotName: "吾爱破解"}, err
+		Logln("Crawler52PoJie goquery.NewDocumentFromReader err:", err)
+		return Result{}, err
 	}
-	doc.Find("#threadlist .bm_c tbody").Each(func(i int, selection *goquery.Selection) {
-		gbkTitle := selection.Find("tr th a[class=xst]").Text()
-		title, err := io.ReadAll(transform.NewReader(bytes.NewReader([]byte(gbkTitle)), simplifiedchinese.GBK.NewDecoder()))
-		if err != nil {
-			fmt.Println("52 gbk to utf8 err:", err)
-		}
-		href := "https://www.52pojie.cn/forum.php?mod=guide&view=hot" + selection.Find("tr th a").AttrOr("href", "")
-		content = append(content, map[string]interface{}{"title": string(title), "href": href})
+	doc.Find(c.selector("item")).Each(func(i int, selection *goquery.Selection) {
+		title := selection.Find(c.selector("title")).Text()
+		href := "https://www.52pojie.cn/forum.php?mod=guide&view=hot" + selection.Find(c.selector("link")).AttrOr("href", "")
+		content = append(content, map[string]interface{}{"title": title, "href": href})
 	})
 
-	return Result{"吾爱破解", content, time.Now().Format("2006-01-02 15:04:05")}, nil
+	return Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}, nil
 }
 
 // CrawlerDouYin 抖音
 func (c Crawler) CrawlerDouYin() (Result, error) {
 	var content []map[string]interface{}
-	url := "https://www.douyin.com/aweme/v1/web/hot/search/list/?device_platform=webapp&aid=6383&channel=channel_pc_w" +
-		"eb&detail_list=1&source=6&pc_client_type=1&version_code=170400&version_name=17.4.0&cookie_enabled=true&screen" +
-		"_width=1440&screen_height=900&browser_language=en&browser_platform=MacIntel&browser_name=Chrome&browser_" +
-		"version=107.0.0.0&browser_online=true&engine_name=Blink&engine_version=107.0.0.0&os_name=Mac+OS&os_version=" +
-		"10.15.7&cpu_core_num=8&device_memory=8&platform=PC&downlink=10&effective_type=4g&round_trip_time=100&webid" +
-		"=7168107943232308770&msToken=x872gxuQF3TKQoShjH0dOxcP5vMWOtp9vE3gAhYMVfvklclynZ5uOj8KsIw_WML0fzol" +
-		"EFqOw4NUSbVwMCIEqGNEs0tFx7hyogm9SI43HP4f__VTIc-mZgOCAjMj7A==&X-Bogus=DFSzswVOS1UANtnuS8c4f37TlqCw"
+	url := c.endpoint()
 	timeout := 5 * time.Second
-	client := &http.Client{
-		Timeout: timeout,
-	}
-	req, err := http.NewRequest("GET", url, nil)
+	client := c.newClient(timeout)
+	req, err := http.NewRequestWithContext(c.requestContext(), "GET", url, nil)
 	if err != nil {
-		fmt.Println("CrawlerDouYin http.NewRequest err:", err)
-		return Result{HotName: "抖音热榜"}, err
+		Logln("CrawlerDouYin http.NewRequest err:", err)
+		return Result{}, err
 	}
 	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/107.0.0.0 Safari/537.36")
 	req.Header.Add("authority", "www.douyin.com")
 	req.Header.Add("referer", "https://www.douyin.com/hot")
 	req.Header.Add("sec-ch-ua-platform", "macOS")
 	req.Header.Add("accept", "application/json, text/plain, */*")
-	res, err := client.Do(req)
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			fmt.Println("err", err)
-		}
-	}(res.Body)
+	body, err := fetch(client, req)
 	if err != nil {
-		fmt.Println("CrawlerDouYin client.Do err:", err)
-		return Result{HotName: "抖音热榜"}, err
+		Logln("CrawlerDouYin fetch err:", err)
+		return Result{}, err
 	}
-	body, _ := io.ReadAll(res.Body)
 	j, err := simplejson.NewJson(body)
 	if err != nil {
-		fmt.Println("")
-		return Result{HotName: "抖音热榜"}, err
+		Logln("CrawlerDouYin simplejson.NewJson err:", err)
+		return Result{}, err
 	}
 	dataJson := j.Get("data").Get("word_list")
 	dataArr := j.Get("data").Get("word_list").MustArray()
@@ -524,66 +453,165 @@ func (c Crawler) CrawlerDouYin() (Result, error) {
 		info := dataJson.GetIndex(index)
 		title := info.Get("word").MustString()
 		href := "https://www.douyin.com/hot/" + info.Get("sentence_id").MustString()
-		content = append(content, map[string]interface{}{"title": title, "href": href})
+		item := map[string]interface{}{"title": title, "href": href}
+		setHeat(item, info.Get("hot_value").Interface())
+		content = append(content, item)
 	}
 
-	return Result{"抖音热榜", content, time.Now().Format("2006-01-02 15:04:05")}, nil
+	return Result{Content: content, CrawlerTime: time.Now().Format("2006-01-02 15:04:05")}, nil
 }
 
-func ExecGetData(c Crawler, cr chan Result) {
-	reflectValue := reflect.ValueOf(c)
-	crawler := reflectValue.MethodByName(c.crawlerName)
-	data := crawler.Call(nil)
-	originData := data[0].Interface().(Result)
-	cr <- originData
-	wg.Done()
+// allCrawler 全部爬虫, 按默认展示顺序排列
+var allCrawler = []string{"CrawlerWeiBo", "CrawlerZhiHu", "CrawlerTieBa", "CrawlerDouBan", "CrawlerTianYa",
+	"CrawlerGithub", "CrawlerWangYiYun", "CrawlerCSDN", "CrawlerWeread", "Crawler52PoJie", "CrawlerDouYin"}
+
+// Sources 返回全部数据源名称
+func Sources() []string {
+	return append([]string(nil), allCrawler...)
 }
 
-var wg sync.WaitGroup
+// DefaultOrder 返回启用的数据源的默认展示顺序: 先按配置的 dashboard.order, 其余按内置顺序
+func DefaultOrder() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range append(config.Get().Dashboard.Order, allCrawler...) {
+		if IsSource(name) && Enabled(name) && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
 
-//RunCrawlerAndWrite  爬取数据并写入文件
-func RunCrawlerAndWrite() {
-	// 文件创建
-	fmt.Println("开始时间：", time.Now().Format("2006-01-02 15:04:05"))
-	allCrawler := []string{"CrawlerWeiBo", "CrawlerZhiHu", "CrawlerTieBa", "CrawlerDouBan", "CrawlerTianYa",
-		"CrawlerGithub", "CrawlerWangYiYun", "CrawlerCSDN", "CrawlerWeread", "Crawler52PoJie", "CrawlerDouYin"}
-	cr := make(chan Result, len(allCrawler))
+// IsSource 是否为已知的数据源名称
+func IsSource(name string) bool {
 	for _, value := range allCrawler {
-		wg.Add(1)
-		fmt.Println("开始抓取" + value)
-		crawler := Crawler{value}
-		go ExecGetData(crawler, cr)
-	}
-	wg.Wait()
-	fmt.Print("抓取结束：", time.Now().Format("2006-01-02 15:04:05"))
-	close(cr)
-	var resultInfo []Result
-	for val := range cr {
-		resultInfo = append(resultInfo, val)
-	}
-	baseDir, _ := os.Getwd()
-	fileLock := new(sync.RWMutex)
-	resultPath := filepath.Join(baseDir, "result.json")
-	fileLock.Lock()
-	file, _ := os.OpenFile(resultPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
-	defer func(file *os.File) {
-		err := file.Close()
-		if err != nil {
-			fmt.Println("err", err)
+		if value == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled 数据源是否启用, 停用的数据源不定时抓取, 页面和接口也不显示
+func Enabled(name string) bool {
+	return !config.SourceOf(name).Disabled
+}
+
+// EnabledSources 按内置顺序返回启用的数据源
+func EnabledSources() []string {
+	var names []string
+	for _, name := range allCrawler {
+		if Enabled(name) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// RunCrawlerAndWrite 爬取全部启用的数据源并写入文件, 已有一轮在进行时等待并返回该轮的结果
+func RunCrawlerAndWrite() []Report {
+	return runRound(context.Background(), EnabledSources())
+}
+
+// runRound 抓取 names 并写入文件, 已有一轮在进行时等待并返回该轮的结果
+// ctx 取消时(失去租约)中止请求、不再开始新的数据源, 也不写入本轮结果, 避免失去租约的 leader 与新 leader 同时写入
+func runRound(ctx context.Context, names []string) []Report {
+	val, err := flights.do(roundKey, func() interface{} {
+		Logln("开始时间：", time.Now().Format("2006-01-02 15:04:05"))
+		// 限制同时运行的爬虫数
+		resultInfo, reports := runPool(ctx, names, config.Get().Politeness.MaxWorkers)
+		Logln("抓取结束：", time.Now().Format("2006-01-02 15:04:05"))
+		if ctx.Err() != nil {
+			Logln("抓取已取消, 不写入本轮结果:", ctx.Err())
+			return reports
+		}
+		WriteResults(resultInfo)
+		return reports
+	})
+	if err != nil {
+		reports := make([]Report, len(names))
+		for i, name := range names {
+			reports[i] = Report{Source: name, HotName: SourceName(name, i18n.Default), Error: err.Error()}
 		}
-	}(file)
-	output, _ := json.Marshal(&resultInfo)
-	file.Write(output)
-	fileLock.Unlock()
+		return reports
+	}
+	return val.([]Report)
 }
 
-func RunTicker() {
-	RunCrawlerAndWrite()
-	// 定时任务, 2分钟爬取一次
-	ticker := time.NewTicker(60 * 10 * time.Second)
+// crawlInterval 默认的定时抓取间隔, 数据源可以用 interval 单独配置
+const crawlInterval = 10 * time.Minute
+
+// schedulerTick 定时任务检查哪些数据源到期的间隔
+const schedulerTick = time.Minute
+
+var (
+	// lastCrawled 各数据源最近一次开始抓取的时间, 定时和手动抓取都会更新
+	lastCrawled   = map[string]time.Time{}
+	lastCrawledMu sync.Mutex
+)
 
-	for range ticker.C {
-		RunCrawlerAndWrite()
+func markCrawled(name string) {
+	lastCrawledMu.Lock()
+	lastCrawled[name] = time.Now()
+	lastCrawledMu.Unlock()
+}
+
+// LastCrawled 返回本实例最近一次开始抓取该数据源的时间, 没有抓取过时为零值
+func LastCrawled(name string) time.Time {
+	lastCrawledMu.Lock()
+	defer lastCrawledMu.Unlock()
+	return lastCrawled[name]
+}
+
+// Interval 返回数据源的定时抓取间隔
+func Interval(name string) time.Duration {
+	if minutes := config.SourceOf(name).Interval; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return crawlInterval
+}
+
+// dueSources 返回到了抓取时间的启用的数据源, 配置的修改在下一次检查时生效
+func dueSources(now time.Time) []string {
+	var names []string
+	for _, name := range EnabledSources() {
+		// 留出半个检查间隔的余量, 避免因为抓取开始时间略晚于检查时间而推迟一整个检查间隔
+		if now.Sub(LastCrawled(name)) >= Interval(name)-schedulerTick/2 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// RunTicker 立即抓取一次, 之后每分钟检查并抓取到期的数据源
+// ctx 取消后不再开始新的一轮, 等当前一轮抓取完成并保存后返回; 只有失去租约时才中止当前一轮, 且不写入结果
+func RunTicker(ctx context.Context) {
+	lease := leaseOf(ctx)
+	if wait := untilDue(); wait > 0 {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+	runRound(lease, EnabledSources())
+	ticker := time.NewTicker(schedulerTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// 上一轮(或手动触发的抓取)还没结束时跳过本次检查
+			if flights.running(roundKey) {
+				continue
+			}
+			if names := dueSources(time.Now()); len(names) > 0 {
+				runRound(lease, names)
+			}
+		}
 	}
 
 }
@@ -0,0 +1,38 @@
+package cralwer
+
+import "testing"
+
+func TestItemText(t *testing.T) {
+	item := map[string]interface{}{"title": " 标题 ", "heat": 1.5e7, "rank": 3, "empty": nil}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"title", "标题"},
+		{"heat", "15000000"},
+		{"rank", "3"},
+		{"empty", ""},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		if got := ItemText(item, tt.key); got != tt.want {
+			t.Errorf("ItemText(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"普通标题", "普通标题"},
+		{"a | b", `a \| b`},
+		{"第一行\r\n第二行", "第一行 第二行"},
+	}
+	for _, tt := range tests {
+		if got := EscapeMarkdown(tt.value); got != tt.want {
+			t.Errorf("EscapeMarkdown(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package cralwer
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// fetch 发送请求并检查状态码, 返回已转换为 UTF-8 的响应体
+func fetch(client *http.Client, req *http.Request) ([]byte, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			Logln("fetch close err:", err)
+		}
+	}(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeBody(body, res.Header.Get("Content-Type"))
+}
+
+// utf8BOM UTF-8 编码的 BOM, UTF-16 的 BOM 解码后也是它
+const utf8BOM = "\xef\xbb\xbf"
+
+// decodeBody 依次按 BOM、Content-Type、<meta charset> 和内容探测确定编码, 并转换为 UTF-8
+func decodeBody(body []byte, contentType string) ([]byte, error) {
+	enc, name := detectCharset(body, contentType)
+	if name != "utf-8" {
+		var err error
+		body, _, err = transform.Bytes(enc.NewDecoder(), body)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s: %w", name, err)
+		}
+	}
+	// 去掉 BOM, 避免影响 JSON 解析
+	return bytes.TrimPrefix(body, []byte(utf8BOM)), nil
+}
+
+// detectCharset 返回响应体的编码及其名称
+func detectCharset(body []byte, contentType string) (encoding.Encoding, string) {
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	// windows-1252 是 DetermineEncoding 找不到任何线索时的默认值, 中文站点基本不会用到, 改为自行探测
+	if certain || name != "windows-1252" {
+		return enc, name
+	}
+	return sniffCharset(body)
+}
+
+// sniffCharset 在没有声明编码时探测 UTF-8、GB18030 和 Big5
+func sniffCharset(body []byte) (encoding.Encoding, string) {
+	if utf8.Valid(body) {
+		return unicode.UTF8, "utf-8"
+	}
+	candidates := []struct {
+		enc  encoding.Encoding
+		name string
+	}{
+		{simplifiedchinese.GB18030, "gb18030"},
+		{traditionalchinese.Big5, "big5"},
+	}
+	best, bestBad := 0, -1
+	for i, candidate := range candidates {
+		decoded, _, err := transform.Bytes(candidate.enc.NewDecoder(), body)
+		if err != nil {
+			continue
+		}
+		// 解码失败的字节会变成替换字符, 替换字符越少越可能是正确编码
+		bad := strings.Count(string(decoded), string(utf8.RuneError))
+		if bestBad == -1 || bad < bestBad {
+			best, bestBad = i, bad
+		}
+	}
+	return candidates[best].enc, candidates[best].name
+}
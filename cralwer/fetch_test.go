@@ -0,0 +1,69 @@
+package cralwer
+
+import "testing"
+
+// 编码样例: 「今日热榜：国足夺冠新闻」的 GBK 编码和「今日熱榜：國足奪冠新聞」的 Big5 编码
+const (
+	gbkTitle  = "\xbd\xf1\xc8\xd5\xc8\xc8\xb0\xf1\xa3\xba\xb9\xfa\xd7\xe3\xb6\xe1\xb9\xda\xd0\xc2\xce\xc5"
+	big5Title = "\xa4\xb5\xa4\xe9\xbc\xf6\xba\x5d\xa1\x47\xb0\xea\xa8\xac\xb9\xdc\xab\x61\xb7\x73\xbb\x44"
+)
+
+func TestDecodeBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		charset     string
+		want        string
+	}{
+		{"utf-8", `{"title":"今日热榜"}`, "application/json", "utf-8", `{"title":"今日热榜"}`},
+		{"utf-8 bom", "\xef\xbb\xbf" + `{"title":"热榜"}`, "", "utf-8", `{"title":"热榜"}`},
+		{"utf-16le bom", "\xff\xfe\xed\x70\x9c\x69", "", "utf-16le", "热榜"},
+		{"gbk header", gbkTitle, "text/html; charset=GBK", "gbk", "今日热榜：国足夺冠新闻"},
+		{"gb2312 header", gbkTitle, "text/plain; charset=gb2312", "gbk", "今日热榜：国足夺冠新闻"},
+		{"big5 header", big5Title, "text/html; charset=big5", "big5", "今日熱榜：國足奪冠新聞"},
+		{"gbk meta", `<html><head><meta charset="gbk"></head><body>` + gbkTitle + `</body></html>`, "text/html",
+			"gbk", `<html><head><meta charset="gbk"></head><body>今日热榜：国足夺冠新闻</body></html>`},
+		{"big5 meta http-equiv", `<meta http-equiv="Content-Type" content="text/html; charset=big5"><p>` + big5Title, "",
+			"big5", `<meta http-equiv="Content-Type" content="text/html; charset=big5"><p>今日熱榜：國足奪冠新聞`},
+		// 响应头与 meta 不一致时以响应头为准
+		{"header over meta", `<meta charset="big5"><p>` + gbkTitle, "text/html; charset=gbk",
+			"gbk", `<meta charset="big5"><p>今日热榜：国足夺冠新闻`},
+		{"header utf-8 over meta", `<meta charset="gbk"><p>热榜`, "text/html; charset=utf-8", "utf-8", `<meta charset="gbk"><p>热榜`},
+		// 没有任何声明时按内容探测
+		{"sniff gb18030", `{"title":"` + gbkTitle + `"}`, "application/json", "gb18030", `{"title":"今日热榜：国足夺冠新闻"}`},
+		{"sniff big5", `{"title":"` + big5Title + `"}`, "application/json", "big5", `{"title":"今日熱榜：國足奪冠新聞"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, name := detectCharset([]byte(tt.body), tt.contentType); name != tt.charset {
+				t.Errorf("charset = %q, want %q", name, tt.charset)
+			}
+			got, err := decodeBody([]byte(tt.body), tt.contentType)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decodeBody = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSniffCharset(t *testing.T) {
+	tests := []struct {
+		body string
+		want string
+	}{
+		{"", "utf-8"},
+		{"plain ascii", "utf-8"},
+		{"热榜", "utf-8"},
+		{gbkTitle, "gb18030"},
+		{big5Title, "big5"},
+	}
+	for _, tt := range tests {
+		if _, name := sniffCharset([]byte(tt.body)); name != tt.want {
+			t.Errorf("sniffCharset(%q) = %q, want %q", tt.body, name, tt.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package cralwer
+
+import (
+	"encoding/json"
+	"fmt"
+	"goCrawlerHot/config"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxFilteredLog 内存中保留的最近被过滤条目数
+const maxFilteredLog = 200
+
+// FilteredItem 一条被过滤的条目及原因, 用于在管理页面审计过滤规则
+type FilteredItem struct {
+	Source string `json:"source"`
+	Title  string `json:"title"`
+	Href   string `json:"href,omitempty"`
+	Reason string `json:"reason"`
+	Time   string `json:"time"`
+}
+
+var (
+	filteredLog   []FilteredItem
+	filteredLogMu sync.Mutex
+)
+
+func recordFiltered(items []FilteredItem) {
+	filteredLogMu.Lock()
+	defer filteredLogMu.Unlock()
+	filteredLog = append(filteredLog, items...)
+	if len(filteredLog) > maxFilteredLog {
+		filteredLog = filteredLog[len(filteredLog)-maxFilteredLog:]
+	}
+}
+
+// RecentFiltered 返回最近被过滤的条目, 最新的在最后
+func RecentFiltered() []FilteredItem {
+	filteredLogMu.Lock()
+	defer filteredLogMu.Unlock()
+	return append([]FilteredItem(nil), filteredLog...)
+}
+
+// filter 编译后的过滤规则
+type filter struct {
+	keywords  []string
+	patterns  []*regexp.Regexp
+	domains   []string
+	positions map[int]bool
+}
+
+var (
+	// patterns 编译过的正则表达式, 全局规则由各数据源共用, 无效的正则只报告一次
+	patterns   = map[string]*regexp.Regexp{}
+	patternsMu sync.Mutex
+)
+
+// compilePattern 编译并缓存正则表达式, 无效时返回 nil
+func compilePattern(pattern string) *regexp.Regexp {
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	if re, ok := patterns[pattern]; ok {
+		return re
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		Logln("filter pattern err:", err)
+	}
+	patterns[pattern] = re
+	return re
+}
+
+// newFilter 合并多组规则, 无效的正则表达式跳过(validate-config 会报告)
+func newFilter(rules ...config.FilterRule) *filter {
+	f := &filter{positions: map[int]bool{}}
+	for _, rule := range rules {
+		for _, keyword := range rule.Keywords {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" {
+				f.keywords = append(f.keywords, keyword)
+			}
+		}
+		for _, pattern := range rule.Patterns {
+			if re := compilePattern(pattern); re != nil {
+				f.patterns = append(f.patterns, re)
+			}
+		}
+		for _, domain := range rule.Domains {
+			if domain = strings.ToLower(strings.Trim(strings.TrimSpace(domain), ".")); domain != "" {
+				f.domains = append(f.domains, domain)
+			}
+		}
+		for _, position := range rule.Positions {
+			f.positions[position] = true
+		}
+	}
+	return f
+}
+
+func (f *filter) empty() bool {
+	return len(f.keywords) == 0 && len(f.patterns) == 0 && len(f.domains) == 0 && len(f.positions) == 0
+}
+
+// match 返回条目被过滤的原因, 为空表示保留, position 为条目在榜单中的位置, 从 1 开始
+func (f *filter) match(item map[string]interface{}, position int) string {
+	if f.positions[position] {
+		return fmt.Sprintf("position %d", position)
+	}
+	title := fmt.Sprint(item["title"])
+	lower := strings.ToLower(title)
+	for _, keyword := range f.keywords {
+		if strings.Contains(lower, keyword) {
+			return "keyword " + keyword
+		}
+	}
+	for _, re := range f.patterns {
+		if re.MatchString(title) {
+			return "pattern " + re.String()
+		}
+	}
+	if len(f.domains) > 0 {
+		href, _ := item["href"].(string)
+		if u, err := url.Parse(href); err == nil {
+			host := strings.ToLower(u.Hostname())
+			for _, domain := range f.domains {
+				if host == domain || strings.HasSuffix(host, "."+domain) {
+					return "domain " + domain
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// cachedFilter 按规则内容缓存的编译结果
+type cachedFilter struct {
+	key    string
+	filter *filter
+}
+
+var (
+	// filters 每个数据源最近使用的过滤规则, 规则不变时不重新编译, 无效的正则也只报告一次
+	filters   = map[string]cachedFilter{}
+	filtersMu sync.Mutex
+)
+
+// filterOf 返回数据源的过滤规则, 配置修改后重新编译
+func filterOf(name string, rules []config.FilterRule) *filter {
+	data, _ := json.Marshal(rules)
+	key := string(data)
+	filtersMu.Lock()
+	defer filtersMu.Unlock()
+	if cached, ok := filters[name]; ok && cached.key == key {
+		return cached.filter
+	}
+	f := newFilter(rules...)
+	filters[name] = cachedFilter{key: key, filter: f}
+	return f
+}
+
+// applyFilter 按全局和数据源的过滤规则过滤条目, 返回保留的条目和被过滤的条数, 被过滤的条目记入最近的过滤记录
+func (c Crawler) applyFilter(content []map[string]interface{}) ([]map[string]interface{}, int) {
+	rules := []config.FilterRule{config.Get().Filter}
+	if rule := c.settings().Filter; rule != nil {
+		rules = append(rules, *rule)
+	}
+	f := filterOf(c.crawlerName, rules)
+	if f.empty() {
+		return content, 0
+	}
+	now := time.Now().Format("2006-01-02 15:04:05")
+	kept := make([]map[string]interface{}, 0, len(content))
+	var removed []FilteredItem
+	for index, item := range content {
+		if reason := f.match(item, index+1); reason != "" {
+			Logf("%s 过滤第 %d 条 %q: %s\n", c.crawlerName, index+1, fmt.Sprint(item["title"]), reason)
+			href, _ := item["href"].(string)
+			removed = append(removed, FilteredItem{Source: c.crawlerName, Title: fmt.Sprint(item["title"]), Href: href, Reason: reason, Time: now})
+			continue
+		}
+		kept = append(kept, item)
+	}
+	// 测试抓取的结果不计入过滤记录
+	if c.capture == nil {
+		recordFiltered(removed)
+	}
+	return kept, len(content) - len(kept)
+}
@@ -0,0 +1,48 @@
+package cralwer
+
+import (
+	"goCrawlerHot/config"
+	"testing"
+)
+
+func TestFilterOfCachesPerRules(t *testing.T) {
+	rules := []config.FilterRule{{Keywords: []string{"ad"}, Patterns: []string{"("}}}
+	first := filterOf("test", rules)
+	if filterOf("test", rules) != first {
+		t.Error("filter recompiled without rule change")
+	}
+	if len(first.patterns) != 0 || len(first.keywords) != 1 {
+		t.Errorf("filter = %+v", first)
+	}
+	changed := filterOf("test", []config.FilterRule{{Keywords: []string{"spam"}}})
+	if changed == first || changed.keywords[0] != "spam" {
+		t.Errorf("filter not rebuilt after rule change: %+v", changed)
+	}
+}
+
+func TestApplyFilterRecordsReasons(t *testing.T) {
+	old := config.Get()
+	defer config.Set(old)
+	cfg := config.Default()
+	cfg.Filter = config.FilterRule{Keywords: []string{"广告"}, Domains: []string{"ads.example.com"}}
+	config.Set(cfg)
+	before := len(RecentFiltered())
+	content := []map[string]interface{}{
+		{"title": "新闻", "href": "https://news.example.com/1"},
+		{"title": "一条广告", "href": "https://news.example.com/2"},
+		{"title": "推广", "href": "https://x.ads.example.com/3"},
+	}
+	kept, filtered := Crawler{crawlerName: "CrawlerZhiHu"}.applyFilter(content)
+	if len(kept) != 1 || filtered != 2 {
+		t.Fatalf("kept %d, filtered %d", len(kept), filtered)
+	}
+	log := RecentFiltered()[before:]
+	if len(log) != 2 || log[0].Reason != "keyword 广告" || log[1].Reason != "domain ads.example.com" || log[1].Href != "https://x.ads.example.com/3" {
+		t.Errorf("filtered log = %+v", log)
+	}
+	// 测试抓取不计入过滤记录
+	Crawler{crawlerName: "CrawlerZhiHu", capture: &capture{}}.applyFilter(content)
+	if len(RecentFiltered()) != before+2 {
+		t.Error("test crawl recorded filtered items")
+	}
+}
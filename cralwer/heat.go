@@ -0,0 +1,118 @@
+package cralwer
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// heatNumber 热度文本: 可选的分类前缀、数字、紧跟数字的单位和可选的热度说明,
+// 如 "剧集 123456"、"1234 万热度"、"1.2亿"、"3.5w"、"12,345 stars today"
+// k、w 必须紧跟数字且后面不能再接字母, 说明只接受常见的热度词, "12 weeks"、"3w comments" 这类文本不是热度
+var heatNumber = regexp.MustCompile(`^(?:\D*[^\dA-Za-z.])?(\d[\d,]*(?:\.\d+)?)(?:([kKwW])|\s*([万亿]))?` +
+	`\s*(?:热度|热|讨论|阅读|浏览|播放|人气|次|(?i:stars?(?:\s+today)?))?$`)
+
+// ParseHeat 从热度文本中解析出数值, 万、亿、w、k 换算为倍数, 不是热度文本时返回 false
+func ParseHeat(label string) (float64, bool) {
+	match := heatNumber.FindStringSubmatch(strings.TrimSpace(label))
+	if match == nil {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(match[1], ",", ""), 64)
+	if err != nil {
+		return 0, false
+	}
+	switch match[2] + match[3] {
+	case "万", "w", "W":
+		value *= 1e4
+	case "亿":
+		value *= 1e8
+	case "k", "K":
+		value *= 1e3
+	}
+	return value, true
+}
+
+// FormatHeat 把热度数值格式化为带万、亿单位的文本
+func FormatHeat(value float64) string {
+	switch {
+	case value >= 1e8:
+		return strconv.FormatFloat(value/1e8, 'f', 1, 64) + "亿"
+	case value >= 1e4:
+		return strconv.FormatFloat(value/1e4, 'f', 1, 64) + "万"
+	default:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+}
+
+// setHeat 写入条目的原始热度文本 heat_label 和解析出的数值 heat, 无法解析时只保留文本
+// label 为数字时直接作为热度, 文本使用 FormatHeat 的格式
+func setHeat(item map[string]interface{}, label interface{}) {
+	switch value := label.(type) {
+	case nil:
+		return
+	case json.Number:
+		if number, err := value.Float64(); err == nil {
+			item["heat"], item["heat_label"] = number, FormatHeat(number)
+		}
+		return
+	case float64:
+		item["heat"], item["heat_label"] = value, FormatHeat(value)
+		return
+	}
+	text := strings.TrimSpace(fmt.Sprint(label))
+	if text == "" {
+		return
+	}
+	item["heat_label"] = text
+	if value, ok := ParseHeat(text); ok {
+		item["heat"] = value
+	}
+}
+
+// HeatOf 返回条目的热度数值, 没有热度时返回 false, 兼容从 JSON 读出的各种数字类型
+func HeatOf(item map[string]interface{}) (float64, bool) {
+	switch value := item["heat"].(type) {
+	case float64:
+		return value, true
+	case int:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case json.Number:
+		number, err := value.Float64()
+		return number, err == nil
+	case string:
+		return ParseHeat(value)
+	default:
+		return 0, false
+	}
+}
+
+// NormalizeTitle 生成用于跨热榜匹配同一话题的标题, 去掉空白和标点并转小写
+func NormalizeTitle(title string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsSymbol(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, title)
+}
+
+// SortByHeat 返回按热度从高到低排列的条目副本, 没有热度的条目保持原顺序排在最后
+func SortByHeat(content []map[string]interface{}) []map[string]interface{} {
+	sorted := append([]map[string]interface{}(nil), content...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, okA := HeatOf(sorted[i])
+		b, okB := HeatOf(sorted[j])
+		if okA != okB {
+			return okA
+		}
+		return okA && a > b
+	})
+	return sorted
+}
@@ -0,0 +1,62 @@
+package cralwer
+
+import "testing"
+
+func TestParseHeat(t *testing.T) {
+	tests := []struct {
+		label string
+		want  float64
+		ok    bool
+	}{
+		{"123456", 123456, true},
+		{" 42 ", 42, true},
+		{"1234 万热度", 12340000, true},
+		{"1234万", 12340000, true},
+		{"1.2亿", 120000000, true},
+		{"3 亿热度", 300000000, true},
+		{"3.5w", 35000, true},
+		{"3.5W热度", 35000, true},
+		{"5k", 5000, true},
+		{"1.5K stars", 1500, true},
+		{"12,345 stars today", 12345, true},
+		{"1,234,567", 1234567, true},
+		{"剧集 123456", 123456, true},
+		{"热度 1.5万", 15000, true},
+		{"2万讨论", 20000, true},
+		{"", 0, false},
+		{"置顶", 0, false},
+		{"热", 0, false},
+		// 单位必须紧跟数字, 数字后面不能接其它词
+		{"12 weeks", 0, false},
+		{"3w comments", 0, false},
+		{"3 w", 0, false},
+		{"5 km", 0, false},
+		{"10kg", 0, false},
+		{"v2", 0, false},
+		{"2026-10-19", 0, false},
+		{"第 3 名", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParseHeat(tt.label)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("ParseHeat(%q) = %v, %v, want %v, %v", tt.label, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFormatHeat(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{9999, "9999"},
+		{12345, "1.2万"},
+		{1.5e8, "1.5亿"},
+	}
+	for _, tt := range tests {
+		if got := FormatHeat(tt.value); got != tt.want {
+			t.Errorf("FormatHeat(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
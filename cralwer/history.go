@@ -0,0 +1,121 @@
+package cralwer
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// historyDir 历史快照目录, 每天一个 JSON Lines 文件, 每行一个数据源的一次抓取结果
+const historyDir = "history"
+
+// TimeLayout 抓取时间的格式
+const TimeLayout = "2006-01-02 15:04:05"
+
+func (s *FileStore) historyPath(day string) string {
+	return filepath.Join(s.baseDir(), historyDir, day+".jsonl")
+}
+
+// appendHistory 把抓取成功的结果追加到抓取当天的历史文件, 调用方需持有 s.mu
+func (s *FileStore) appendHistory(results []Result) error {
+	files := map[string]*os.File{}
+	defer func() {
+		for _, file := range files {
+			err := file.Close()
+			if err != nil {
+				Logln("history close err:", err)
+			}
+		}
+	}()
+	for _, result := range results {
+		if len(result.CrawlerTime) < len("2006-01-02") {
+			continue
+		}
+		day := result.CrawlerTime[:len("2006-01-02")]
+		file, ok := files[day]
+		if !ok {
+			err := os.MkdirAll(filepath.Dir(s.historyPath(day)), 0755)
+			if err != nil {
+				return err
+			}
+			file, err = os.OpenFile(s.historyPath(day), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+			if err != nil {
+				return err
+			}
+			files[day] = file
+		}
+		line, _ := json.Marshal(result)
+		_, err := file.Write(append(line, '\n'))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Range 按时间顺序返回抓取时间在 [from, to] 之间的历史结果, source 为空时返回全部数据源
+func (s *FileStore) Range(from, to time.Time, source string) ([]Result, error) {
+	entries, err := os.ReadDir(filepath.Join(s.baseDir(), historyDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	firstDay, lastDay := from.Format("2006-01-02"), to.Format("2006-01-02")
+	var results []Result
+	// 文件名即日期, ReadDir 按文件名排序, 结果也就按时间排序
+	for _, entry := range entries {
+		day := strings.TrimSuffix(entry.Name(), ".jsonl")
+		if entry.IsDir() || day == entry.Name() || day < firstDay || day > lastDay {
+			continue
+		}
+		dayResults, err := s.readHistoryDay(day)
+		if err != nil {
+			return results, err
+		}
+		for _, result := range dayResults {
+			crawlerTime, err := time.ParseInLocation(TimeLayout, result.CrawlerTime, time.Local)
+			if err != nil || crawlerTime.Before(from) || crawlerTime.After(to) {
+				continue
+			}
+			if source != "" && result.Source != source && result.HotName != source {
+				continue
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// readHistoryDay 读取一天的历史文件, 文件不存在时返回空
+func (s *FileStore) readHistoryDay(day string) ([]Result, error) {
+	file, err := os.Open(s.historyPath(day))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			Logln("history close err:", err)
+		}
+	}(file)
+	var results []Result
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var result Result
+		// 进程退出时可能留下不完整的最后一行, 跳过无法解析的行
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, scanner.Err()
+}
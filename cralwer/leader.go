@@ -0,0 +1,196 @@
+package cralwer
+
+import (
+	"context"
+	"fmt"
+	"goCrawlerHot/config"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Elector 多个实例共享存储时选出唯一的 leader, 由存储实现
+type Elector interface {
+	// Acquire 获取或续期租约, 返回当前实例是否持有租约
+	Acquire(id string, ttl time.Duration) (bool, error)
+	// Release 释放自己持有的租约, 让其它实例尽快接手
+	Release(id string) error
+}
+
+// NodeID 本实例的标识, 未配置时使用主机名和进程号
+func NodeID() string {
+	if id := config.Get().Cluster.NodeID; id != "" {
+		return id
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// leaseKey 任务 context 中保存租约 context 的键
+type leaseKey struct{}
+
+// withLease 把租约的 context 放入任务的 context: 任务的 context 取消表示不再安排新的抓取,
+// 租约的 context 取消表示失去了租约, 需要中止正在进行的抓取
+func withLease(ctx, lease context.Context) context.Context {
+	return context.WithValue(ctx, leaseKey{}, lease)
+}
+
+// leaseOf 返回任务的租约 context, 未开启集群模式时不会被取消
+func leaseOf(ctx context.Context) context.Context {
+	if lease, ok := ctx.Value(leaseKey{}).(context.Context); ok {
+		return lease
+	}
+	return context.Background()
+}
+
+// RunAsLeader 运行同一时间只应由一个实例执行的定时任务, ctx 取消后任务不再安排新的工作, 等待任务返回
+// 未开启集群模式时直接运行; 开启时只在持有租约期间运行, 失去租约时取消任务并中止正在进行的抓取, 退出时释放租约
+func RunAsLeader(ctx context.Context, tasks ...func(context.Context)) {
+	cluster := config.Get().Cluster
+	elector, ok := currentStore().(Elector)
+	if !cluster.Enabled || !ok {
+		runTasks(ctx, tasks)
+		return
+	}
+	id := NodeID()
+	ttl := time.Duration(cluster.LeaseTTL) * time.Second
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+
+	// stopTasks 取消任务, loseLease 中止任务中正在进行的抓取, 都只在失去租约时调用, 正常退出时由 ctx 停止任务
+	var stopTasks, loseLease context.CancelFunc
+	// done 任务全部返回后关闭, 失去租约时只取消任务不等待, 不阻塞续期和选举
+	var done chan struct{}
+	// stopped 上一次启动的任务是否都已返回, 未返回时不重新启动, 避免同时运行两份任务
+	stopped := func() bool {
+		if done == nil {
+			return true
+		}
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+	for {
+		// 无法确认租约时(如存储不可用)按失去租约处理, 避免出现两个 leader
+		leader, err := elector.Acquire(id, ttl)
+		if err != nil {
+			Logln("acquire lease err:", err)
+		}
+		if leader && loseLease == nil && stopped() && ctx.Err() == nil {
+			Logln(id, "成为 leader, 开始定时任务")
+			lease, cancelLease := context.WithCancel(context.Background())
+			taskCtx, cancelTasks := context.WithCancel(withLease(ctx, lease))
+			stopTasks, loseLease = cancelTasks, cancelLease
+			done = make(chan struct{})
+			go func() {
+				runTasks(taskCtx, tasks)
+				close(done)
+			}()
+		} else if !leader && loseLease != nil {
+			Logln(id, "失去租约, 停止定时任务")
+			loseLease()
+			stopTasks()
+			stopTasks, loseLease = nil, nil
+		}
+		// 退出时等待当前一轮抓取完成并保存, 期间继续续期, 避免其它实例在此期间接手
+		if ctx.Err() != nil && stopped() {
+			if loseLease != nil {
+				loseLease()
+				stopTasks()
+			}
+			err = elector.Release(id)
+			if err != nil {
+				Logln("release lease err:", err)
+			}
+			return
+		}
+		wait := ctx.Done()
+		if ctx.Err() != nil {
+			wait = done
+		}
+		select {
+		case <-wait:
+		case <-ticker.C:
+		}
+	}
+}
+
+// runTasks 并发运行全部任务并等待它们返回
+func runTasks(ctx context.Context, tasks []func(context.Context)) {
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task func(context.Context)) {
+			defer wg.Done()
+			task(ctx)
+		}(task)
+	}
+	wg.Wait()
+}
+
+// untilDue 集群模式下新 leader 接手时, 存储中的数据可能刚由上一个 leader 抓取, 返回距下一轮抓取还需等待的时间
+func untilDue() time.Duration {
+	if !config.Get().Cluster.Enabled {
+		return 0
+	}
+	results, err := ReadResults()
+	if err != nil {
+		return 0
+	}
+	var latest time.Time
+	for _, result := range results {
+		crawlerTime, err := time.ParseInLocation(TimeLayout, result.CrawlerTime, time.Local)
+		if err == nil && crawlerTime.After(latest) {
+			latest = crawlerTime
+		}
+	}
+	if latest.IsZero() {
+		return 0
+	}
+	return time.Until(latest.Add(crawlInterval))
+}
+
+// leaderLock 文件存储的租约, 用操作系统的文件锁实现, 只在同一台机器的实例间有效, 进程退出时自动释放
+type leaderLock struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// Acquire 尝试锁定数据目录下的 leader.lock, 已持有时直接返回 true
+func (s *FileStore) Acquire(id string, _ time.Duration) (bool, error) {
+	s.lock.mu.Lock()
+	defer s.lock.mu.Unlock()
+	if s.lock.file != nil {
+		return true, nil
+	}
+	file, err := os.OpenFile(filepath.Join(s.baseDir(), "leader.lock"), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return false, err
+	}
+	locked, err := lockFile(file)
+	if !locked {
+		_ = file.Close()
+		return false, err
+	}
+	// 记录持有者, 方便排查
+	_ = file.Truncate(0)
+	_, _ = file.WriteAt([]byte(id+"\n"), 0)
+	s.lock.file = file
+	return true, nil
+}
+
+// Release 关闭锁文件即释放锁
+func (s *FileStore) Release(_ string) error {
+	s.lock.mu.Lock()
+	defer s.lock.mu.Unlock()
+	if s.lock.file == nil {
+		return nil
+	}
+	err := s.lock.file.Close()
+	s.lock.file = nil
+	return err
+}
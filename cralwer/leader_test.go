@@ -0,0 +1,107 @@
+package cralwer
+
+import (
+	"context"
+	"goCrawlerHot/config"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeElector 由测试控制是否持有租约的存储
+type fakeElector struct {
+	FileStore
+	mu       sync.Mutex
+	leader   bool
+	released bool
+}
+
+func (e *fakeElector) Acquire(string, time.Duration) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader, nil
+}
+
+func (e *fakeElector) Release(string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.released = true
+	return nil
+}
+
+func (e *fakeElector) setLeader(leader bool) {
+	e.mu.Lock()
+	e.leader = leader
+	e.mu.Unlock()
+}
+
+// runLeader 以集群模式运行 RunAsLeader, 返回任务收到的 context 和 RunAsLeader 返回后关闭的 channel
+func runLeader(t *testing.T, ctx context.Context, elector *fakeElector) (<-chan context.Context, <-chan struct{}) {
+	t.Helper()
+	oldConfig, oldStore := config.Get(), currentStore()
+	t.Cleanup(func() {
+		config.Set(oldConfig)
+		SetStore(oldStore)
+	})
+	cfg := config.Default()
+	cfg.Cluster.Enabled = true
+	cfg.Cluster.LeaseTTL = 1
+	config.Set(cfg)
+	SetStore(elector)
+
+	started := make(chan context.Context, 1)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	done := make(chan struct{})
+	go func() {
+		RunAsLeader(ctx, func(taskCtx context.Context) {
+			started <- taskCtx
+			// 模拟正在进行的一轮抓取, 只在失去租约或测试结束时中止
+			select {
+			case <-leaseOf(taskCtx).Done():
+			case <-release:
+			}
+		})
+		close(done)
+	}()
+	return started, done
+}
+
+func TestRunAsLeaderShutdownKeepsRound(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	elector := &fakeElector{leader: true}
+	started, done := runLeader(t, ctx, elector)
+	taskCtx := <-started
+	cancel()
+	<-taskCtx.Done()
+	// 退出时不中止当前一轮, 等它结束后才释放租约
+	select {
+	case <-leaseOf(taskCtx).Done():
+		t.Fatal("lease cancelled on shutdown")
+	case <-done:
+		t.Fatal("returned before the task finished")
+	case <-time.After(500 * time.Millisecond):
+	}
+}
+
+func TestRunAsLeaderLostLease(t *testing.T) {
+	elector := &fakeElector{leader: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	started, done := runLeader(t, ctx, elector)
+	taskCtx := <-started
+	elector.setLeader(false)
+	select {
+	case <-leaseOf(taskCtx).Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("lease not cancelled after losing it")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("RunAsLeader did not return")
+	}
+	if !elector.released {
+		t.Error("lease not released on exit")
+	}
+}
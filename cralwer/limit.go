@@ -0,0 +1,291 @@
+package cralwer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"goCrawlerHot/config"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errRobotsDisallowed robots.txt 禁止抓取该地址
+var errRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+// robotsTTL robots.txt 的缓存时间
+const robotsTTL = time.Hour
+
+// robotsRetry robots.txt 无法获取(5xx 或网络错误)时按全部禁止处理, 之后隔多久重新获取
+const robotsRetry = 5 * time.Minute
+
+// hostLimiter 单个站点的令牌桶限速和并发限制
+type hostLimiter struct {
+	mu sync.Mutex
+	// config 创建时的配置, 配置修改后重新创建
+	config config.HostLimit
+	limit  config.HostLimit
+	tokens float64
+	last   time.Time
+	slots  chan struct{}
+}
+
+func newHostLimiter(limit config.HostLimit) *hostLimiter {
+	cfg := limit
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	if limit.MaxConcurrent < 1 {
+		limit.MaxConcurrent = 1
+	}
+	return &hostLimiter{
+		config: cfg,
+		limit:  limit,
+		tokens: float64(limit.Burst),
+		last:   time.Now(),
+		slots:  make(chan struct{}, limit.MaxConcurrent),
+	}
+}
+
+// wait 等待令牌, RatePerSecond 不大于 0 时不限速
+func (l *hostLimiter) wait(req *http.Request) error {
+	if l.limit.RatePerSecond <= 0 {
+		return nil
+	}
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.tokens += now.Sub(l.last).Seconds() * l.limit.RatePerSecond
+		if l.tokens > float64(l.limit.Burst) {
+			l.tokens = float64(l.limit.Burst)
+		}
+		l.last = now
+		if l.tokens >= 1 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := time.Duration((1 - l.tokens) / l.limit.RatePerSecond * float64(time.Second))
+		l.mu.Unlock()
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return req.Context().Err()
+		}
+	}
+}
+
+// robotsRule 一条 Allow 或 Disallow 规则, 支持 * 匹配任意字符和结尾的 $ 匹配路径结束
+type robotsRule struct {
+	pattern string
+	// re 含有通配符的规则编译后的正则, 不含通配符时按前缀匹配
+	re *regexp.Regexp
+}
+
+func newRobotsRule(pattern string) robotsRule {
+	rule := robotsRule{pattern: pattern}
+	if strings.ContainsAny(pattern, "*$") {
+		expr := regexp.QuoteMeta(strings.TrimSuffix(pattern, "$"))
+		expr = "^" + strings.ReplaceAll(expr, `\*`, ".*")
+		if strings.HasSuffix(pattern, "$") {
+			expr += "$"
+		}
+		rule.re = regexp.MustCompile(expr)
+	}
+	return rule
+}
+
+func (r robotsRule) match(path string) bool {
+	if r.re != nil {
+		return r.re.MatchString(path)
+	}
+	return strings.HasPrefix(path, r.pattern)
+}
+
+// robotsRules 一个站点 robots.txt 中适用于所有爬虫(User-agent: *)的规则
+type robotsRules struct {
+	allow    []robotsRule
+	disallow []robotsRule
+	// disallowAll robots.txt 无法获取时全部禁止(RFC 9309)
+	disallowAll bool
+	expires     time.Time
+}
+
+// allowed 按最长匹配规则判断路径是否允许抓取, Allow 与 Disallow 等长时 Allow 优先
+func (r *robotsRules) allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	longest, allow := -1, true
+	for _, rule := range r.disallow {
+		if len(rule.pattern) > longest && rule.match(path) {
+			longest, allow = len(rule.pattern), false
+		}
+	}
+	for _, rule := range r.allow {
+		if len(rule.pattern) >= longest && rule.match(path) {
+			longest, allow = len(rule.pattern), true
+		}
+	}
+	return allow
+}
+
+// parseRobots 解析 robots.txt, 只保留 User-agent: * 分组
+func parseRobots(r io.Reader) *robotsRules {
+	rules := &robotsRules{expires: time.Now().Add(robotsTTL)}
+	scanner := bufio.NewScanner(r)
+	inGroup, lastWasAgent := false, false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+		switch key {
+		case "user-agent":
+			// 连续的 User-agent 行属于同一分组
+			if !lastWasAgent {
+				inGroup = false
+			}
+			if value == "*" {
+				inGroup = true
+			}
+			lastWasAgent = true
+			continue
+		case "allow":
+			if inGroup && value != "" {
+				rules.allow = append(rules.allow, newRobotsRule(value))
+			}
+		case "disallow":
+			if inGroup && value != "" {
+				rules.disallow = append(rules.disallow, newRobotsRule(value))
+			}
+		}
+		lastWasAgent = false
+	}
+	return rules
+}
+
+// limitTransport 按站点限速、限制并发, 并可选遵守 robots.txt
+type limitTransport struct {
+	base     http.RoundTripper
+	mu       sync.Mutex
+	limiters map[string]*hostLimiter
+	robots   map[string]*robotsRules
+}
+
+// politeTransport 所有数据源共享的限速 Transport
+var politeTransport = &limitTransport{
+	base:     proxies,
+	limiters: map[string]*hostLimiter{},
+	robots:   map[string]*robotsRules{},
+}
+
+// limiter 返回站点的限速器, 站点的限速配置修改后重新创建, 进行中的请求仍在旧的限速器中释放名额
+func (t *limitTransport) limiter(host string) *hostLimiter {
+	limit := config.HostLimitOf(host)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	l, ok := t.limiters[host]
+	if !ok || l.config != limit {
+		l = newHostLimiter(limit)
+		t.limiters[host] = l
+	}
+	return l
+}
+
+// robotsOf 返回站点的 robots.txt 规则: 2xx 时按内容解析, 4xx 时视为全部允许,
+// 5xx 或网络错误时视为全部禁止并在 robotsRetry 后重新获取(RFC 9309)
+func (t *limitTransport) robotsOf(req *http.Request) *robotsRules {
+	host := req.URL.Host
+	t.mu.Lock()
+	rules, ok := t.robots[host]
+	t.mu.Unlock()
+	if ok && time.Now().Before(rules.expires) {
+		return rules
+	}
+	rules = &robotsRules{disallowAll: true, expires: time.Now().Add(robotsRetry)}
+	robotsReq, err := http.NewRequestWithContext(req.Context(), "GET", req.URL.Scheme+"://"+host+"/robots.txt", nil)
+	if err != nil {
+		Logln("robots.txt http.NewRequest err:", err)
+		return rules
+	}
+	robotsReq.Header.Set("User-Agent", req.Header.Get("User-Agent"))
+	// 按 RFC 9309 最多跟随 5 次重定向
+	client := &http.Client{Transport: t.base, CheckRedirect: func(_ *http.Request, via []*http.Request) error {
+		if len(via) > 5 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	}}
+	res, err := client.Do(robotsReq)
+	if err != nil {
+		Logln("robots.txt fetch err:", err)
+		if req.Context().Err() != nil {
+			// 请求已取消, 不缓存
+			return rules
+		}
+	} else {
+		switch {
+		case res.StatusCode >= 200 && res.StatusCode < 300:
+			rules = parseRobots(res.Body)
+		case res.StatusCode >= 400 && res.StatusCode < 500:
+			rules = &robotsRules{expires: time.Now().Add(robotsTTL)}
+		default:
+			Logln("robots.txt status code error:", host, res.StatusCode)
+		}
+		err = res.Body.Close()
+		if err != nil {
+			Logln("robots.txt close err:", err)
+		}
+	}
+	t.mu.Lock()
+	t.robots[host] = rules
+	t.mu.Unlock()
+	return rules
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if config.Get().Politeness.RespectRobots && !t.robotsOf(req).allowed(req.URL.EscapedPath()) {
+		return nil, fmt.Errorf("%s: %w", req.URL, errRobotsDisallowed)
+	}
+	l := t.limiter(req.URL.Host)
+	select {
+	case l.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	if err := l.wait(req); err != nil {
+		<-l.slots
+		return nil, err
+	}
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		<-l.slots
+		return nil, err
+	}
+	// 响应体读取完并关闭后才释放并发名额
+	res.Body = &releaseBody{ReadCloser: res.Body, release: func() { <-l.slots }}
+	return res, nil
+}
+
+// releaseBody 关闭时释放站点并发名额
+type releaseBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
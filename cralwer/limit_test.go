@@ -0,0 +1,93 @@
+package cralwer
+
+import (
+	"goCrawlerHot/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRobotsAllowed(t *testing.T) {
+	rules := parseRobots(strings.NewReader(`
+User-agent: other
+Disallow: /
+
+User-agent: *
+Disallow: /private
+Allow: /private/public
+Disallow: /*.json$
+Disallow: /search*q=
+Allow: /api/*/open
+Disallow: /api/
+`))
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true},
+		{"/private", false},
+		{"/private/x", false},
+		{"/private/public/x", true},
+		{"/data.json", false},
+		{"/data.json?x=1", true},
+		{"/a/b/data.json", false},
+		{"/search?q=go", false},
+		{"/search?page=1", true},
+		{"/api/v1/open", true},
+		{"/api/v1/closed", false},
+	}
+	for _, tt := range tests {
+		if got := rules.allowed(tt.path); got != tt.want {
+			t.Errorf("allowed(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRobotsOfStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   bool
+	}{
+		{"ok", http.StatusOK, "User-agent: *\nDisallow: /hot\n", false},
+		{"ok allows other paths", http.StatusOK, "User-agent: *\nDisallow: /other\n", true},
+		{"not found", http.StatusNotFound, "", true},
+		{"forbidden", http.StatusForbidden, "", true},
+		{"server error", http.StatusServiceUnavailable, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				writer.WriteHeader(tt.status)
+				_, _ = writer.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+			transport := &limitTransport{base: http.DefaultTransport, limiters: map[string]*hostLimiter{}, robots: map[string]*robotsRules{}}
+			req := httptest.NewRequest("GET", server.URL+"/hot", nil)
+			if got := transport.robotsOf(req).allowed("/hot"); got != tt.want {
+				t.Errorf("allowed = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiterFollowsConfig(t *testing.T) {
+	old := config.Get()
+	defer config.Set(old)
+	transport := &limitTransport{limiters: map[string]*hostLimiter{}}
+	cfg := config.Default()
+	cfg.Politeness.Default = config.HostLimit{RatePerSecond: 1, Burst: 1, MaxConcurrent: 1}
+	config.Set(cfg)
+	first := transport.limiter("example.com")
+	if transport.limiter("example.com") != first {
+		t.Fatal("limiter recreated without config change")
+	}
+	cfg.Politeness.Hosts = map[string]config.HostLimit{"example.com": {RatePerSecond: 5, Burst: 2, MaxConcurrent: 3}}
+	config.Set(cfg)
+	second := transport.limiter("example.com")
+	if second == first || cap(second.slots) != 3 {
+		t.Errorf("limiter not rebuilt after config change: %+v", second.limit)
+	}
+}
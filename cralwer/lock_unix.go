@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package cralwer
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile 以非阻塞方式加排他锁, 已被其它进程锁定时返回 false
+func lockFile(file *os.File) (bool, error) {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		return false, nil
+	}
+	return err == nil, err
+}
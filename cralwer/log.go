@@ -0,0 +1,35 @@
+package cralwer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	// logOutput 抓取日志的输出, 默认为标准输出
+	logOutput   io.Writer = os.Stdout
+	logOutputMu sync.RWMutex
+)
+
+// SetLogOutput 替换抓取日志的输出, crawl -once 改为标准错误, 让标准输出只有结果, 方便管道处理
+func SetLogOutput(w io.Writer) {
+	logOutputMu.Lock()
+	logOutput = w
+	logOutputMu.Unlock()
+}
+
+// Logln 输出一行抓取日志, 分类器等在抓取期间运行的代码也使用它
+func Logln(a ...interface{}) {
+	logOutputMu.RLock()
+	defer logOutputMu.RUnlock()
+	_, _ = fmt.Fprintln(logOutput, a...)
+}
+
+// Logf 按格式输出抓取日志
+func Logf(format string, a ...interface{}) {
+	logOutputMu.RLock()
+	defer logOutputMu.RUnlock()
+	_, _ = fmt.Fprintf(logOutput, format, a...)
+}
@@ -0,0 +1,268 @@
+package cralwer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"goCrawlerHot/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ReadRetention 已读记录保留的时长, 条目早已下榜后的已读记录没有用处
+const ReadRetention = 7 * 24 * time.Hour
+
+// ErrMarksUnsupported 当前存储不支持已读和收藏
+var ErrMarksUnsupported = errors.New("storage does not support read marks and bookmarks")
+
+// 各存储对已读记录和收藏的限制, 避免不断更换 cookie 的匿名请求无限增加数据
+const (
+	// MaxMarkUsers 最多保存的用户数, 达到上限时先清理已读记录全部过期且没有收藏的用户
+	MaxMarkUsers = 10000
+	// MaxReadMarks 每个用户最多保留的已读记录数, 超出时删除最早的记录
+	MaxReadMarks = 5000
+	// MarkPruneInterval 用户数达到上限时清理过期用户的最小间隔, 避免每个新用户的请求都扫描全部用户
+	MarkPruneInterval = time.Minute
+)
+
+// ErrTooManyUsers 保存已读记录和收藏的用户数已达上限
+var ErrTooManyUsers = errors.New("too many users")
+
+// Bookmark 用户收藏的条目, 保存收藏时的标题和链接, 条目下榜后仍然可以查看
+type Bookmark struct {
+	Key       string `json:"key"`
+	Source    string `json:"source"`
+	Title     string `json:"title"`
+	Href      string `json:"href"`
+	CreatedAt string `json:"created_at"`
+}
+
+// MarkStore 保存每个用户的已读记录和收藏, 由存储实现
+type MarkStore interface {
+	// MarkRead 把条目标记为已读, 同时清理超过 ReadRetention 的记录
+	MarkRead(user string, keys []string, at time.Time) error
+	// MarkUnread 取消已读
+	MarkUnread(user string, keys []string) error
+	// ReadKeys 返回 since 之后标记为已读的条目
+	ReadKeys(user string, since time.Time) ([]string, error)
+	// SaveBookmark 添加收藏, 已收藏时更新标题和链接
+	SaveBookmark(user string, bookmark Bookmark) error
+	DeleteBookmark(user, key string) error
+	// Bookmarks 按收藏时间从新到旧返回
+	Bookmarks(user string) ([]Bookmark, error)
+}
+
+// Marks 返回当前存储的 MarkStore
+func Marks() (MarkStore, error) {
+	marks, ok := currentStore().(MarkStore)
+	if !ok {
+		return nil, ErrMarksUnsupported
+	}
+	return marks, nil
+}
+
+// ItemKey 条目的标识, 同一数据源上标题相同(按 NormalizeTitle 比较)的条目视为同一条目
+func ItemKey(source, title string) string {
+	sum := sha1.Sum([]byte(source + "\n" + NormalizeTitle(title)))
+	return hex.EncodeToString(sum[:10])
+}
+
+// SortBookmarks 按收藏时间从新到旧排列
+func SortBookmarks(bookmarks []Bookmark) {
+	sort.SliceStable(bookmarks, func(i, j int) bool {
+		return bookmarks[i].CreatedAt > bookmarks[j].CreatedAt
+	})
+}
+
+// userMarks 文件存储中一个用户的数据
+type userMarks struct {
+	// Read 条目标识到标记时间(unix 秒)
+	Read      map[string]int64    `json:"read"`
+	Bookmarks map[string]Bookmark `json:"bookmarks"`
+}
+
+// expire 删除 now 之前 ReadRetention 以外的已读记录, 超过 MaxReadMarks 时只保留最近的记录
+func (m *userMarks) expire(now time.Time) {
+	expired := now.Add(-ReadRetention).Unix()
+	for key, readAt := range m.Read {
+		if readAt < expired {
+			delete(m.Read, key)
+		}
+	}
+	if len(m.Read) <= MaxReadMarks {
+		return
+	}
+	keys := make([]string, 0, len(m.Read))
+	for key := range m.Read {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m.Read[keys[i]] != m.Read[keys[j]] {
+			return m.Read[keys[i]] > m.Read[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys[MaxReadMarks:] {
+		delete(m.Read, key)
+	}
+}
+
+// empty 没有任何已读记录和收藏
+func (m userMarks) empty() bool {
+	return len(m.Read) == 0 && len(m.Bookmarks) == 0
+}
+
+// marksPath 用户数据文件, 文件名使用用户标识的哈希, 避免用户名中的特殊字符
+func (s *FileStore) marksPath(user string) string {
+	sum := sha1.Sum([]byte(user))
+	return filepath.Join(s.baseDir(), "users", hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *FileStore) readMarks(user string) (userMarks, error) {
+	marks := userMarks{Read: map[string]int64{}, Bookmarks: map[string]Bookmark{}}
+	data, err := os.ReadFile(s.marksPath(user))
+	if os.IsNotExist(err) {
+		return marks, nil
+	}
+	if err != nil {
+		return marks, err
+	}
+	err = json.Unmarshal(data, &marks)
+	if marks.Read == nil {
+		marks.Read = map[string]int64{}
+	}
+	if marks.Bookmarks == nil {
+		marks.Bookmarks = map[string]Bookmark{}
+	}
+	return marks, err
+}
+
+// updateMarks 读取、修改并写回用户数据
+func (s *FileStore) updateMarks(user string, update func(*userMarks)) error {
+	s.marksMu.Lock()
+	defer s.marksMu.Unlock()
+	marks, err := s.readMarks(user)
+	if err != nil {
+		return err
+	}
+	update(&marks)
+	path := s.marksPath(user)
+	_, err = os.Stat(path)
+	exists := !os.IsNotExist(err)
+	if marks.empty() {
+		// 没有任何数据时不保留文件, 不占用用户数
+		if exists {
+			return os.Remove(path)
+		}
+		return nil
+	}
+	if !exists && s.usersFull() {
+		return ErrTooManyUsers
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(marks)
+	if err != nil {
+		return err
+	}
+	return config.WriteFile(path, data, 0644)
+}
+
+// usersFull 用户数是否已达 MaxMarkUsers, 达到时删除已读记录全部过期且没有收藏的用户文件后再检查, 调用时需持有 marksMu
+func (s *FileStore) usersFull() bool {
+	dir := filepath.Join(s.baseDir(), "users")
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) < MaxMarkUsers {
+		return false
+	}
+	if time.Since(s.marksPruned) < MarkPruneInterval {
+		return true
+	}
+	s.marksPruned = time.Now()
+	count := len(entries)
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var marks userMarks
+		if json.Unmarshal(data, &marks) != nil {
+			continue
+		}
+		marks.expire(s.marksPruned)
+		if marks.empty() && os.Remove(path) == nil {
+			count--
+		}
+	}
+	return count >= MaxMarkUsers
+}
+
+func (s *FileStore) MarkRead(user string, keys []string, at time.Time) error {
+	return s.updateMarks(user, func(marks *userMarks) {
+		for _, key := range keys {
+			marks.Read[key] = at.Unix()
+		}
+		marks.expire(at)
+	})
+}
+
+func (s *FileStore) MarkUnread(user string, keys []string) error {
+	return s.updateMarks(user, func(marks *userMarks) {
+		for _, key := range keys {
+			delete(marks.Read, key)
+		}
+	})
+}
+
+func (s *FileStore) ReadKeys(user string, since time.Time) ([]string, error) {
+	s.marksMu.Lock()
+	marks, err := s.readMarks(user)
+	s.marksMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for key, readAt := range marks.Read {
+		if readAt >= since.Unix() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *FileStore) SaveBookmark(user string, bookmark Bookmark) error {
+	return s.updateMarks(user, func(marks *userMarks) {
+		if old, ok := marks.Bookmarks[bookmark.Key]; ok {
+			bookmark.CreatedAt = old.CreatedAt
+		}
+		marks.Bookmarks[bookmark.Key] = bookmark
+	})
+}
+
+func (s *FileStore) DeleteBookmark(user, key string) error {
+	return s.updateMarks(user, func(marks *userMarks) {
+		delete(marks.Bookmarks, key)
+	})
+}
+
+func (s *FileStore) Bookmarks(user string) ([]Bookmark, error) {
+	s.marksMu.Lock()
+	marks, err := s.readMarks(user)
+	s.marksMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	bookmarks := []Bookmark{}
+	for _, bookmark := range marks.Bookmarks {
+		bookmarks = append(bookmarks, bookmark)
+	}
+	SortBookmarks(bookmarks)
+	return bookmarks, nil
+}
@@ -0,0 +1,83 @@
+package cralwer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUserMarksExpire(t *testing.T) {
+	now := time.Now()
+	marks := userMarks{Read: map[string]int64{}, Bookmarks: map[string]Bookmark{}}
+	marks.Read["expired"] = now.Add(-ReadRetention - time.Second).Unix()
+	for i := 0; i < MaxReadMarks; i++ {
+		marks.Read[fmt.Sprint("new", i)] = now.Unix()
+	}
+	marks.Read["oldest"] = now.Add(-time.Hour).Unix()
+	marks.expire(now)
+	if len(marks.Read) != MaxReadMarks {
+		t.Fatalf("kept %d read marks, want %d", len(marks.Read), MaxReadMarks)
+	}
+	for _, key := range []string{"expired", "oldest"} {
+		if _, ok := marks.Read[key]; ok {
+			t.Errorf("%s not removed", key)
+		}
+	}
+}
+
+// fillUsers 在文件存储中写入 MaxMarkUsers 个用户文件
+func fillUsers(t *testing.T, s *FileStore, data string) {
+	t.Helper()
+	dir := filepath.Join(s.baseDir(), "users")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < MaxMarkUsers; i++ {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.json", i)), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileStoreUserLimit(t *testing.T) {
+	now := time.Now()
+	t.Run("prune expired users", func(t *testing.T) {
+		s := NewFileStore(t.TempDir())
+		fillUsers(t, s, fmt.Sprintf(`{"read":{"k":%d}}`, now.Add(-ReadRetention-time.Hour).Unix()))
+		if err := s.MarkRead("new", []string{"k"}, now); err != nil {
+			t.Fatal(err)
+		}
+		entries, _ := os.ReadDir(filepath.Join(s.baseDir(), "users"))
+		if len(entries) != 1 {
+			t.Errorf("%d user files after prune, want 1", len(entries))
+		}
+	})
+	t.Run("full", func(t *testing.T) {
+		s := NewFileStore(t.TempDir())
+		fillUsers(t, s, `{"bookmarks":{"k":{"key":"k"}}}`)
+		err := s.SaveBookmark("new", Bookmark{Key: "k"})
+		if !errors.Is(err, ErrTooManyUsers) {
+			t.Fatalf("SaveBookmark = %v, want ErrTooManyUsers", err)
+		}
+		// 没有数据的写入不创建文件, 不受用户数限制
+		err = s.MarkRead("new", nil, now)
+		if err != nil {
+			t.Errorf("empty MarkRead = %v", err)
+		}
+	})
+	t.Run("remove empty user", func(t *testing.T) {
+		s := NewFileStore(t.TempDir())
+		if err := s.SaveBookmark("u", Bookmark{Key: "k"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.DeleteBookmark("u", "k"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(s.marksPath("u")); !os.IsNotExist(err) {
+			t.Errorf("user file kept after deleting all data: %v", err)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package cralwer
+
+import (
+	"context"
+	"encoding/json"
+	"goCrawlerHot/config"
+	"time"
+)
+
+// OverrideStore 保存管理页面对数据源配置的修改, 由共享存储实现,
+// 集群中每个实例都从存储读取修改, 不依赖处理请求的实例的配置文件
+type OverrideStore interface {
+	SaveOverride(name string, override config.SourceOverride) error
+	// Overrides 返回全部修改过的数据源
+	Overrides() (map[string]config.SourceOverride, error)
+}
+
+// sharedOverrides 集群模式且存储支持时返回 OverrideStore
+func sharedOverrides() (OverrideStore, bool) {
+	if !config.Get().Cluster.Enabled {
+		return nil, false
+	}
+	overrides, ok := currentStore().(OverrideStore)
+	return overrides, ok
+}
+
+// SaveSource 保存管理页面修改的数据源配置并在本实例立即生效
+// 集群模式下保存到共享存储, 其它实例由 SyncOverrides 在下一次检查时读取; 否则写回配置文件
+func SaveSource(name string, source config.Source) error {
+	shared, ok := sharedOverrides()
+	if !ok {
+		return config.SaveSource(name, source)
+	}
+	err := config.CheckSource(name, source)
+	if err != nil {
+		return err
+	}
+	err = shared.SaveOverride(name, source.Override())
+	if err != nil {
+		return err
+	}
+	return config.SetSource(name, source)
+}
+
+// LoadOverrides 把共享存储中的数据源配置修改应用到当前配置, 未开启集群模式或存储不支持时不做任何事
+func LoadOverrides() {
+	shared, ok := sharedOverrides()
+	if !ok {
+		return
+	}
+	overrides, err := shared.Overrides()
+	if err != nil {
+		Logln("load source overrides err:", err)
+		return
+	}
+	for name, override := range overrides {
+		if !IsSource(name) {
+			continue
+		}
+		// 按 JSON 比较, 空 map 与 nil 视为相同
+		old, _ := json.Marshal(config.SourceOf(name).Override())
+		updated, _ := json.Marshal(override)
+		if string(old) == string(updated) {
+			continue
+		}
+		err = config.SetSource(name, override.Apply(config.SourceOf(name)))
+		if err != nil {
+			Logln("apply source override err:", name, err)
+			continue
+		}
+		Logln("数据源配置已同步:", name)
+	}
+}
+
+// SyncOverrides 每隔 interval 读取其它实例保存的数据源配置修改, ctx 取消后返回
+func SyncOverrides(ctx context.Context, interval time.Duration) {
+	if _, ok := sharedOverrides(); !ok {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			LoadOverrides()
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package cralwer
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// 分页策略
+const (
+	PageNumber   = iota // 页码分页, 如 page=0,1,2
+	PageOffset          // 偏移量分页, 如 offset=0,25,50
+	PageCursor          // 游标分页, 下一页游标由上一页返回
+	PageNextLink        // 链接分页, 下一页地址由上一页返回
+)
+
+// Page 单页抓取结果
+type Page struct {
+	Items []map[string]interface{}
+	// Next 游标分页时为下一页游标, 链接分页时为下一页地址, 为空表示没有下一页
+	Next string
+}
+
+// Paginator 通用分页抓取
+type Paginator struct {
+	Strategy int
+	// URL 根据页码、偏移量或游标生成页面地址, 游标分页和链接分页的第一页传入空字符串
+	URL func(pos string) string
+	// Start 起始页码或偏移量
+	Start int
+	// Step 偏移量分页每页的步长, 必须大于 0, 页码分页忽略
+	Step int
+	// MaxPages 最多抓取页数, 0 表示不限制(游标和链接分页仍以 Next 为空或重复结束), 最多抓取 maxPages 页
+	MaxPages int
+	// MaxItems 最多保留条数, 0 表示不限制
+	MaxItems int
+	// StopOnEmpty 遇到空页时停止抓取
+	StopOnEmpty bool
+	// Concurrency 页码和偏移量分页的并发抓取数, 游标和链接分页总是顺序抓取
+	Concurrency int
+}
+
+// maxPages 未设置 MaxPages 时最多抓取的页数, 防止站点忽略分页参数时无限抓取
+const maxPages = 100
+
+var (
+	errUnbounded   = errors.New("paginator: MaxPages or StopOnEmpty is required for page/offset strategies")
+	errInvalidStep = errors.New("paginator: Step must be positive for the offset strategy")
+)
+
+// pageLimit 返回最多抓取的页数
+func (p Paginator) pageLimit() int {
+	if p.MaxPages > 0 {
+		return p.MaxPages
+	}
+	return maxPages
+}
+
+// Run 按分页策略抓取并合并各页条目, 出错时返回出错前已抓取的条目
+func (p Paginator) Run(fetch func(url string) (Page, error)) ([]map[string]interface{}, error) {
+	switch p.Strategy {
+	case PageCursor, PageNextLink:
+		return p.runSequential(fetch)
+	default:
+		return p.runIndexed(fetch)
+	}
+}
+
+// runIndexed 页码和偏移量分页, 页地址可以提前算出, 因此按批并发抓取
+func (p Paginator) runIndexed(fetch func(url string) (Page, error)) ([]map[string]interface{}, error) {
+	concurrency := p.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if p.MaxPages == 0 && !p.StopOnEmpty {
+		return nil, errUnbounded
+	}
+	step := 1
+	if p.Strategy == PageOffset {
+		if p.Step <= 0 {
+			return nil, errInvalidStep
+		}
+		step = p.Step
+	}
+	limit := p.pageLimit()
+	var content []map[string]interface{}
+	for index := 0; index < limit; index += concurrency {
+		batch := concurrency
+		if index+batch > limit {
+			batch = limit - index
+		}
+		pages := make([]Page, batch)
+		errs := make([]error, batch)
+		var pageWg sync.WaitGroup
+		for i := 0; i < batch; i++ {
+			pageWg.Add(1)
+			go func(i int) {
+				defer pageWg.Done()
+				pos := strconv.Itoa(p.Start + (index+i)*step)
+				pages[i], errs[i] = fetch(p.URL(pos))
+			}(i)
+		}
+		pageWg.Wait()
+		// 按页顺序合并, 保证结果顺序与单线程抓取一致
+		for i := 0; i < batch; i++ {
+			if errs[i] != nil {
+				return content, errs[i]
+			}
+			if len(pages[i].Items) == 0 && p.StopOnEmpty {
+				return content, nil
+			}
+			var full bool
+			content, full = p.appendItems(content, pages[i].Items)
+			if full {
+				return content, nil
+			}
+		}
+	}
+	return content, nil
+}
+
+// runSequential 游标和链接分页, 下一页依赖上一页的结果, 下一页地址与抓取过的页面重复时结束
+func (p Paginator) runSequential(fetch func(url string) (Page, error)) ([]map[string]interface{}, error) {
+	var content []map[string]interface{}
+	url := p.URL("")
+	seen := map[string]bool{}
+	for index := 0; index < p.pageLimit() && !seen[url]; index++ {
+		seen[url] = true
+		page, err := fetch(url)
+		if err != nil {
+			return content, err
+		}
+		if len(page.Items) == 0 && p.StopOnEmpty {
+			break
+		}
+		var full bool
+		content, full = p.appendItems(content, page.Items)
+		if full || page.Next == "" {
+			break
+		}
+		if p.Strategy == PageCursor {
+			url = p.URL(page.Next)
+		} else {
+			url = page.Next
+		}
+	}
+	return content, nil
+}
+
+// appendItems 合并条目并按 MaxItems 截断, 返回是否已满
+func (p Paginator) appendItems(content, items []map[string]interface{}) ([]map[string]interface{}, bool) {
+	content = append(content, items...)
+	if p.MaxItems > 0 && len(content) >= p.MaxItems {
+		return content[:p.MaxItems], true
+	}
+	return content, false
+}
@@ -0,0 +1,183 @@
+package cralwer
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSite 按地址返回预先准备的页面, 记录请求过的地址
+type fakeSite struct {
+	mu        sync.Mutex
+	pages     map[string]Page
+	errs      map[string]error
+	requested []string
+}
+
+func (s *fakeSite) fetch(url string) (Page, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requested = append(s.requested, url)
+	if err := s.errs[url]; err != nil {
+		return Page{}, err
+	}
+	return s.pages[url], nil
+}
+
+// items 生成标题为 names 的条目
+func items(names ...string) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, name := range names {
+		result = append(result, map[string]interface{}{"title": name})
+	}
+	return result
+}
+
+func titles(content []map[string]interface{}) []string {
+	var result []string
+	for _, item := range content {
+		result = append(result, item["title"].(string))
+	}
+	return result
+}
+
+func prefixURL(prefix string) func(string) string {
+	return func(pos string) string {
+		return prefix + pos
+	}
+}
+
+func TestPaginatorRun(t *testing.T) {
+	errBoom := errors.New("boom")
+	tests := []struct {
+		name      string
+		paginator Paginator
+		pages     map[string]Page
+		errs      map[string]error
+		want      []string
+		wantErr   error
+		// requests 期望的请求数, -1 表示不检查
+		requests int
+	}{
+		{
+			name:      "page number with max pages",
+			paginator: Paginator{Strategy: PageNumber, URL: prefixURL("p"), MaxPages: 3, Concurrency: 2},
+			pages:     map[string]Page{"p0": {Items: items("a")}, "p1": {Items: items("b")}, "p2": {Items: items("c")}, "p3": {Items: items("d")}},
+			want:      []string{"a", "b", "c"},
+			requests:  3,
+		},
+		{
+			name:      "page number stops on empty",
+			paginator: Paginator{Strategy: PageNumber, URL: prefixURL("p"), Start: 1, StopOnEmpty: true},
+			pages:     map[string]Page{"p1": {Items: items("a")}, "p2": {Items: items("b")}},
+			want:      []string{"a", "b"},
+			requests:  3,
+		},
+		{
+			name:      "page number without bound",
+			paginator: Paginator{Strategy: PageNumber, URL: prefixURL("p")},
+			wantErr:   errUnbounded,
+			requests:  0,
+		},
+		{
+			name:      "page number capped when pages never run out",
+			paginator: Paginator{Strategy: PageNumber, URL: func(string) string { return "same" }, StopOnEmpty: true, Concurrency: 8},
+			pages:     map[string]Page{"same": {Items: items("a")}},
+			requests:  maxPages,
+		},
+		{
+			name:      "offset uses step",
+			paginator: Paginator{Strategy: PageOffset, URL: prefixURL("o"), Step: 25, MaxPages: 3, Concurrency: 3},
+			pages:     map[string]Page{"o0": {Items: items("a")}, "o25": {Items: items("b")}, "o50": {Items: items("c")}},
+			want:      []string{"a", "b", "c"},
+			requests:  3,
+		},
+		{
+			name:      "offset rejects zero step",
+			paginator: Paginator{Strategy: PageOffset, URL: prefixURL("o"), StopOnEmpty: true},
+			wantErr:   errInvalidStep,
+			requests:  0,
+		},
+		{
+			name:      "offset rejects negative step",
+			paginator: Paginator{Strategy: PageOffset, URL: prefixURL("o"), Step: -10, MaxPages: 2},
+			wantErr:   errInvalidStep,
+			requests:  0,
+		},
+		{
+			name:      "max items truncates",
+			paginator: Paginator{Strategy: PageNumber, URL: prefixURL("p"), MaxPages: 5, MaxItems: 3},
+			pages:     map[string]Page{"p0": {Items: items("a", "b")}, "p1": {Items: items("c", "d")}, "p2": {Items: items("e")}},
+			want:      []string{"a", "b", "c"},
+			requests:  2,
+		},
+		{
+			name:      "error keeps earlier pages",
+			paginator: Paginator{Strategy: PageNumber, URL: prefixURL("p"), MaxPages: 3},
+			pages:     map[string]Page{"p0": {Items: items("a")}, "p2": {Items: items("c")}},
+			errs:      map[string]error{"p1": errBoom},
+			want:      []string{"a"},
+			wantErr:   errBoom,
+			requests:  2,
+		},
+		{
+			name:      "cursor follows next",
+			paginator: Paginator{Strategy: PageCursor, URL: prefixURL("c?")},
+			pages:     map[string]Page{"c?": {Items: items("a"), Next: "x"}, "c?x": {Items: items("b"), Next: "y"}, "c?y": {Items: items("c")}},
+			want:      []string{"a", "b", "c"},
+			requests:  3,
+		},
+		{
+			name:      "cursor stops on repeated cursor",
+			paginator: Paginator{Strategy: PageCursor, URL: prefixURL("c?")},
+			pages:     map[string]Page{"c?": {Items: items("a"), Next: "x"}, "c?x": {Items: items("b"), Next: "x"}},
+			want:      []string{"a", "b"},
+			requests:  2,
+		},
+		{
+			name:      "cursor max pages",
+			paginator: Paginator{Strategy: PageCursor, URL: prefixURL("c?"), MaxPages: 2},
+			pages:     map[string]Page{"c?": {Items: items("a"), Next: "x"}, "c?x": {Items: items("b"), Next: "y"}, "c?y": {Items: items("c")}},
+			want:      []string{"a", "b"},
+			requests:  2,
+		},
+		{
+			name:      "next link follows links",
+			paginator: Paginator{Strategy: PageNextLink, URL: prefixURL("/list")},
+			pages:     map[string]Page{"/list": {Items: items("a"), Next: "/list?2"}, "/list?2": {Items: items("b")}},
+			want:      []string{"a", "b"},
+			requests:  2,
+		},
+		{
+			name:      "next link stops when linking back",
+			paginator: Paginator{Strategy: PageNextLink, URL: prefixURL("/list")},
+			pages:     map[string]Page{"/list": {Items: items("a"), Next: "/list?2"}, "/list?2": {Items: items("b"), Next: "/list"}},
+			want:      []string{"a", "b"},
+			requests:  2,
+		},
+		{
+			name:      "next link stops on empty",
+			paginator: Paginator{Strategy: PageNextLink, URL: prefixURL("/list"), StopOnEmpty: true},
+			pages:     map[string]Page{"/list": {Items: items("a"), Next: "/list?2"}, "/list?2": {Next: "/list?3"}, "/list?3": {Items: items("c")}},
+			want:      []string{"a"},
+			requests:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := &fakeSite{pages: tt.pages, errs: tt.errs}
+			content, err := tt.paginator.Run(site.fetch)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := titles(content); tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("titles = %v, want %v", got, tt.want)
+			}
+			if tt.requests >= 0 && len(site.requested) != tt.requests {
+				t.Errorf("requested %d pages, want %d: %s", len(site.requested), tt.requests, strings.Join(site.requested, " "))
+			}
+		})
+	}
+}
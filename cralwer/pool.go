@@ -0,0 +1,159 @@
+package cralwer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goCrawlerHot/i18n"
+	"reflect"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// maxFailures 内存中保留的最近失败记录数
+const maxFailures = 100
+
+// Failure 一次抓取失败的记录, panic 时带有调用栈
+type Failure struct {
+	Source string `json:"source"`
+	Error  string `json:"error"`
+	Stack  string `json:"stack,omitempty"`
+	Time   string `json:"time"`
+}
+
+var (
+	failures   []Failure
+	failuresMu sync.Mutex
+)
+
+func recordFailure(f Failure) {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	failures = append(failures, f)
+	if len(failures) > maxFailures {
+		failures = failures[len(failures)-maxFailures:]
+	}
+}
+
+// RecentFailures 返回最近的抓取失败记录, 最新的在最后
+func RecentFailures() []Failure {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	return append([]Failure(nil), failures...)
+}
+
+// errCrawlerPanic 爬虫发生 panic, 此时没有可用结果
+var errCrawlerPanic = errors.New("crawler panic")
+
+// ExecGetData 执行单个爬虫并记录失败, 爬虫 panic 时恢复并返回 errCrawlerPanic
+func ExecGetData(c Crawler) (result Result, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			f := Failure{
+				Source: c.crawlerName,
+				Error:  fmt.Sprint("panic: ", r),
+				Stack:  string(debug.Stack()),
+				Time:   time.Now().Format("2006-01-02 15:04:05"),
+			}
+			Logf("%s %s\n%s", f.Source, f.Error, f.Stack)
+			if c.capture == nil {
+				recordFailure(f)
+			}
+			result, err = Result{Source: c.crawlerName, HotName: SourceName(c.crawlerName, i18n.Default)}, fmt.Errorf("%w: %v", errCrawlerPanic, r)
+		}
+	}()
+	crawler := reflect.ValueOf(c).MethodByName(c.crawlerName)
+	if !crawler.IsValid() {
+		panic("unknown crawler " + c.crawlerName)
+	}
+	data := crawler.Call(nil)
+	result = data[0].Interface().(Result)
+	result.Source = c.crawlerName
+	// 存储中的 hot_name 固定使用默认语言, 页面按数据源 ID 显示对应语言的名称
+	result.HotName = SourceName(c.crawlerName, i18n.Default)
+	result.Content, result.Filtered = c.applyFilter(result.Content)
+	c.classify(result.Content)
+	// 测试抓取的失败不计入最近的失败记录
+	if err, _ = data[1].Interface().(error); err != nil && c.capture == nil {
+		recordFailure(Failure{
+			Source: c.crawlerName,
+			Error:  err.Error(),
+			Time:   time.Now().Format("2006-01-02 15:04:05"),
+		})
+	}
+	return result, err
+}
+
+// Report 一个数据源一次抓取的结果摘要
+type Report struct {
+	Source      string `json:"source"`
+	HotName     string `json:"hot_name"`
+	Count       int    `json:"count"`
+	Filtered    int    `json:"filtered,omitempty"`
+	CrawlerTime string `json:"crawler_time"`
+	Error       string `json:"error,omitempty"`
+}
+
+func newReport(result Result, err error) Report {
+	report := Report{
+		Source:      result.Source,
+		HotName:     result.HotName,
+		Count:       len(result.Content),
+		Filtered:    result.Filtered,
+		CrawlerTime: result.CrawlerTime,
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	return report
+}
+
+// runPool 用最多 workers 个 goroutine 运行爬虫, 按 names 的顺序返回结果和摘要, 单个爬虫 panic 不影响其它爬虫
+// ctx 取消后不再开始剩余的数据源, 它们的摘要中记录取消的原因
+func runPool(ctx context.Context, names []string, workers int) ([]Result, []Report) {
+	if workers < 1 || workers > len(names) {
+		workers = len(names)
+	}
+	type job struct {
+		index int
+		name  string
+	}
+	jobs := make(chan job)
+	results := make([]Result, len(names))
+	errs := make([]error, len(names))
+	var poolWg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		poolWg.Add(1)
+		go func() {
+			defer poolWg.Done()
+			for j := range jobs {
+				Logln("开始抓取" + j.name)
+				results[j.index], errs[j.index] = crawlOnce(ctx, j.name)
+			}
+		}()
+	}
+dispatch:
+	for index, name := range names {
+		select {
+		case jobs <- job{index, name}:
+		case <-ctx.Done():
+			for i := index; i < len(names); i++ {
+				results[i] = Result{Source: names[i], HotName: SourceName(names[i], i18n.Default)}
+				errs[i] = ctx.Err()
+			}
+			break dispatch
+		}
+	}
+	close(jobs)
+	poolWg.Wait()
+	var resultInfo []Result
+	reports := make([]Report, len(names))
+	for index, result := range results {
+		reports[index] = newReport(result, errs[index])
+		if !errors.Is(errs[index], errCrawlerPanic) {
+			resultInfo = append(resultInfo, result)
+		}
+	}
+	return resultInfo, reports
+}
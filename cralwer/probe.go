@@ -0,0 +1,103 @@
+package cralwer
+
+import (
+	"bytes"
+	"fmt"
+	"goCrawlerHot/config"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxCapturedBody 测试抓取时每个响应最多保留的字节数
+const maxCapturedBody = 512 * 1024
+
+// Exchange 测试抓取时的一次请求和原始响应
+type Exchange struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	// Body 转换为 UTF-8 的响应体, 超过 maxCapturedBody 时截断
+	Body      string `json:"body,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// capture 记录一次测试抓取的全部请求, 分页的数据源会并发请求
+type capture struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+func (c *capture) add(e Exchange) {
+	c.mu.Lock()
+	c.exchanges = append(c.exchanges, e)
+	c.mu.Unlock()
+}
+
+// captureTransport 读出响应体记录下来, 再交给爬虫解析
+type captureTransport struct {
+	base    http.RoundTripper
+	capture *capture
+}
+
+func (t *captureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	exchange := Exchange{Method: req.Method, URL: req.URL.String()}
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		exchange.Error = err.Error()
+		t.capture.add(exchange)
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		exchange.Error = err.Error()
+	}
+	exchange.Status = res.StatusCode
+	exchange.ContentType = res.Header.Get("Content-Type")
+	if len(body) > maxCapturedBody {
+		body, exchange.Truncated = body[:maxCapturedBody], true
+	}
+	if text, err := decodeBody(body, exchange.ContentType); err == nil {
+		body = text
+	}
+	exchange.Body = string(body)
+	t.capture.add(exchange)
+	return res, err
+}
+
+// Probe 测试抓取的结果, 包含解析出的条目和原始响应
+type Probe struct {
+	Source   string                   `json:"source"`
+	Items    []map[string]interface{} `json:"items"`
+	Filtered int                      `json:"filtered"`
+	Error    string                   `json:"error,omitempty"`
+	Duration float64                  `json:"duration"`
+	// Responses 按完成顺序排列的请求和原始响应
+	Responses []Exchange `json:"responses"`
+}
+
+// TestCrawl 用 draft 配置抓取一次数据源, 不写入存储、不计入失败记录, draft 为 nil 时使用当前配置, 停用的数据源也可以测试
+func TestCrawl(name string, draft *config.Source) (Probe, error) {
+	if !IsSource(name) {
+		return Probe{}, fmt.Errorf("unknown source %q", name)
+	}
+	c := Crawler{crawlerName: name, draft: draft, capture: &capture{}}
+	start := time.Now()
+	result, err := ExecGetData(c)
+	probe := Probe{
+		Source:    name,
+		Items:     result.Content,
+		Filtered:  result.Filtered,
+		Duration:  time.Since(start).Seconds(),
+		Responses: c.capture.exchanges,
+	}
+	if err != nil {
+		probe.Error = err.Error()
+	}
+	return probe, nil
+}
@@ -0,0 +1,200 @@
+package cralwer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goCrawlerHot/config"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sourceKey 请求上下文中保存数据源名称的键, 代理按数据源选择
+type sourceKey struct{}
+
+// withSource 在请求上下文中记录数据源名称
+func withSource(req *http.Request, name string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), sourceKey{}, name))
+}
+
+func sourceOf(req *http.Request) string {
+	name, _ := req.Context().Value(sourceKey{}).(string)
+	return name
+}
+
+// proxyEntry 一个代理及其健康状态
+type proxyEntry struct {
+	url       *url.URL
+	transport *http.Transport
+	mu        sync.Mutex
+	healthy   bool
+	lastErr   error
+}
+
+func (p *proxyEntry) setHealthy(healthy bool, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.healthy != healthy {
+		Logf("代理 %s 状态变化: healthy=%v err=%v\n", p.url.Redacted(), healthy, err)
+	}
+	p.healthy, p.lastErr = healthy, err
+}
+
+func (p *proxyEntry) isHealthy() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.healthy
+}
+
+// proxyPool 一组轮换使用的代理
+type proxyPool struct {
+	entries []*proxyEntry
+	mu      sync.Mutex
+	next    int
+}
+
+// candidates 从下一个轮换位置开始返回本次请求依次尝试的代理, 健康的排在前面
+func (p *proxyPool) candidates() []*proxyEntry {
+	p.mu.Lock()
+	start := p.next
+	p.next = (p.next + 1) % len(p.entries)
+	p.mu.Unlock()
+	var healthy, unhealthy []*proxyEntry
+	for i := range p.entries {
+		entry := p.entries[(start+i)%len(p.entries)]
+		if entry.isHealthy() {
+			healthy = append(healthy, entry)
+		} else {
+			unhealthy = append(unhealthy, entry)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// proxyTransport 按数据源选择代理, 请求失败时切换到下一个代理
+type proxyTransport struct {
+	mu      sync.Mutex
+	entries map[string]*proxyEntry
+	pools   map[string]*proxyPool
+	checker sync.Once
+}
+
+var proxies = &proxyTransport{
+	entries: map[string]*proxyEntry{},
+	pools:   map[string]*proxyPool{},
+}
+
+// entry 返回代理地址对应的代理, 同一地址在各个数据源之间共享健康状态
+func (t *proxyTransport) entry(rawURL string) (*proxyEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if entry, ok := t.entries[rawURL]; ok {
+		return entry, nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(u)
+	entry := &proxyEntry{url: u, transport: transport, healthy: true}
+	t.entries[rawURL] = entry
+	return entry, nil
+}
+
+// pool 返回数据源的代理池, 代理配置变化后自动生成新的代理池
+func (t *proxyTransport) pool(source string) (*proxyPool, error) {
+	urls := config.ProxiesOf(source)
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	key := strings.Join(urls, ",")
+	t.mu.Lock()
+	pool, ok := t.pools[key]
+	t.mu.Unlock()
+	if ok {
+		return pool, nil
+	}
+	pool = &proxyPool{}
+	for _, rawURL := range urls {
+		entry, err := t.entry(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("proxy %s: %w", rawURL, err)
+		}
+		pool.entries = append(pool.entries, entry)
+	}
+	t.mu.Lock()
+	t.pools[key] = pool
+	t.mu.Unlock()
+	t.checker.Do(func() { go t.runHealthCheck() })
+	return pool, nil
+}
+
+func (t *proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	pool, err := t.pool(sourceOf(req))
+	if err != nil {
+		return nil, err
+	}
+	if pool == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	var errs []string
+	for _, entry := range pool.candidates() {
+		res, err := entry.transport.RoundTrip(req)
+		if err == nil {
+			return res, nil
+		}
+		if req.Context().Err() != nil {
+			return nil, err
+		}
+		entry.setHealthy(false, err)
+		errs = append(errs, entry.url.Redacted()+": "+err.Error())
+	}
+	return nil, errors.New("all proxies failed: " + strings.Join(errs, "; "))
+}
+
+// runHealthCheck 定时通过每个代理访问健康检查地址, 恢复可用的代理, 剔除不可用的代理
+func (t *proxyTransport) runHealthCheck() {
+	for {
+		cfg := config.Get().Proxy
+		if cfg.HealthCheckInterval <= 0 || cfg.HealthCheckURL == "" {
+			time.Sleep(time.Minute)
+			continue
+		}
+		t.mu.Lock()
+		entries := make([]*proxyEntry, 0, len(t.entries))
+		for _, entry := range t.entries {
+			entries = append(entries, entry)
+		}
+		t.mu.Unlock()
+		for _, entry := range entries {
+			entry.setHealthy(checkProxy(entry, cfg.HealthCheckURL))
+		}
+		time.Sleep(time.Duration(cfg.HealthCheckInterval) * time.Second)
+	}
+}
+
+// checkProxy 通过代理请求健康检查地址, 返回代理是否可用
+func checkProxy(entry *proxyEntry, checkURL string) (bool, error) {
+	client := &http.Client{Transport: entry.transport, Timeout: 10 * time.Second}
+	res, err := client.Get(checkURL)
+	if err != nil {
+		return false, err
+	}
+	err = res.Body.Close()
+	if err != nil {
+		Logln("proxy health check close err:", err)
+	}
+	if res.StatusCode >= 500 || res.StatusCode == http.StatusProxyAuthRequired {
+		return false, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+	return true, nil
+}
@@ -0,0 +1,163 @@
+package cralwer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"goCrawlerHot/config"
+	"goCrawlerHot/i18n"
+	"runtime/debug"
+	"sync"
+)
+
+// roundKey 整轮抓取在 flights 中使用的键
+const roundKey = "*"
+
+// call 一次正在进行的调用, 结束后关闭 done
+type call struct {
+	done chan struct{}
+	val  interface{}
+	// err fn panic 时的错误, 此时 val 为 nil
+	err error
+}
+
+// flightGroup 相同键的并发调用只执行一次, 后来者等待并共享第一次调用的结果
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+var flights = &flightGroup{calls: map[string]*call{}}
+
+// do 执行 fn 或等待正在进行的同一键的调用, fn panic 时恢复, 调用者和等待者都得到 errFlightPanic
+func (g *flightGroup) do(key string, fn func() interface{}) (val interface{}, err error) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-c.done
+		return c.val, c.err
+	}
+	c := &call{done: make(chan struct{})}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.err = fmt.Errorf("%w: %v", errFlightPanic, r)
+			Logf("%s %v\n%s", key, c.err, debug.Stack())
+			err = c.err
+		}
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+	c.val = fn()
+	return c.val, c.err
+}
+
+// errFlightPanic 合并执行的调用发生 panic
+var errFlightPanic = errors.New("panic")
+
+// running 返回该键是否有调用正在进行
+func (g *flightGroup) running(key string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.calls[key]
+	return ok
+}
+
+type crawlOutcome struct {
+	result Result
+	err    error
+}
+
+// crawlOnce 抓取单个数据源, 同一数据源同时只有一次抓取在进行
+func crawlOnce(ctx context.Context, name string) (Result, error) {
+	val, err := flights.do(name, func() interface{} {
+		markCrawled(name)
+		result, err := ExecGetData(Crawler{crawlerName: name, ctx: ctx})
+		return crawlOutcome{result, err}
+	})
+	if err != nil {
+		// 与爬虫 panic 相同, 没有可用结果
+		return Result{Source: name, HotName: SourceName(name, i18n.Default)}, fmt.Errorf("%w: %v", errCrawlerPanic, err)
+	}
+	outcome := val.(crawlOutcome)
+	return outcome.result, outcome.err
+}
+
+// mergeResults 用新结果替换同一数据源较旧的结果, 抓取失败(没有抓取时间)的结果不会覆盖已有数据, 新数据源追加在最后
+func mergeResults(old, updates []Result) []Result {
+	key := ResultKey
+	merged := make([]Result, len(old))
+	copy(merged, old)
+	index := map[string]int{}
+	for i, r := range merged {
+		index[key(r)] = i
+		// 旧文件中没有 source 字段时按名称匹配
+		index[r.HotName] = i
+	}
+	for _, r := range updates {
+		i, ok := index[key(r)]
+		if !ok {
+			i, ok = index[r.HotName]
+		}
+		if !ok {
+			index[key(r)] = len(merged)
+			merged = append(merged, r)
+			continue
+		}
+		if r.CrawlerTime >= merged[i].CrawlerTime {
+			merged[i] = r
+		}
+	}
+	return merged
+}
+
+// WriteResults 把新结果保存到当前存储, 成功后调用 OnSave 注册的函数
+func WriteResults(updates []Result) {
+	err := currentStore().Save(updates)
+	if err != nil {
+		Logln("save result err:", err)
+		return
+	}
+	saveHooksMu.RLock()
+	defer saveHooksMu.RUnlock()
+	for _, fn := range saveHooks {
+		fn(updates)
+	}
+}
+
+// Refresh 立即抓取指定数据源并写入结果, source 为空时抓取全部启用的数据源, 与正在进行的相同抓取合并
+// 集群模式下任一实例都可以手动抓取, 不经过 leader: 存储按抓取时间合并, 不会用旧结果覆盖新结果
+func Refresh(source string) ([]Report, error) {
+	if source == "" {
+		return RunCrawlerAndWrite(), nil
+	}
+	if !IsSource(source) {
+		return nil, fmt.Errorf("unknown source %q", source)
+	}
+	if !Enabled(source) {
+		return nil, fmt.Errorf("source %q is disabled", source)
+	}
+	result, err := crawlOnce(context.Background(), source)
+	if err == nil || result.CrawlerTime != "" {
+		WriteResults([]Result{result})
+	}
+	return []Report{newReport(result, err)}, nil
+}
+
+// Crawl 抓取指定数据源但不写入文件, names 为空时抓取全部启用的数据源
+func Crawl(names []string) ([]Result, []Report, error) {
+	if len(names) == 0 {
+		names = EnabledSources()
+	}
+	for _, name := range names {
+		if !IsSource(name) {
+			return nil, nil, fmt.Errorf("unknown source %q", name)
+		}
+	}
+	results, reports := runPool(context.Background(), names, config.Get().Politeness.MaxWorkers)
+	return results, reports, nil
+}
@@ -0,0 +1,51 @@
+package cralwer
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFlightGroupPanic(t *testing.T) {
+	g := &flightGroup{calls: map[string]*call{}}
+	started, release := make(chan struct{}), make(chan struct{})
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, errs[0] = g.do("k", func() interface{} {
+			close(started)
+			<-release
+			panic("boom")
+		})
+	}()
+	<-started
+	go func() {
+		defer wg.Done()
+		val, err := g.do("k", func() interface{} {
+			panic("boom")
+		})
+		if val != nil {
+			t.Errorf("waiter val = %v", val)
+		}
+		errs[1] = err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for i, err := range errs {
+		if !errors.Is(err, errFlightPanic) {
+			t.Errorf("caller %d err = %v", i, err)
+		}
+	}
+	if g.running("k") {
+		t.Error("call not removed after panic")
+	}
+	// panic 之后同一键可以再次执行
+	val, err := g.do("k", func() interface{} { return 1 })
+	if err != nil || val != 1 {
+		t.Errorf("do after panic = %v, %v", val, err)
+	}
+}
@@ -0,0 +1,357 @@
+package cralwer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"goCrawlerHot/config"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cookieDir 会话 cookie 的持久化目录, 每个数据源一个文件
+const cookieDir = "cookies"
+
+const userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36"
+
+// challengeStatus 表示会话失效或遇到验证页的状态码, 命中时刷新会话并重试一次
+var challengeStatus = map[int]bool{
+	http.StatusUnauthorized:       true,
+	http.StatusForbidden:          true,
+	http.StatusPreconditionFailed: true,
+}
+
+// challengeTitles 验证页的标题, 站点返回 200 的验证页时按标题识别, 不检查正文, 避免热榜标题中的词造成误判
+var challengeTitles = []string{"Sina Visitor System", "Just a moment", "Attention Required", "Security Verification", "验证码", "安全验证", "人机验证"}
+
+// challengeRedirects 重定向到这些地址时表示需要登录或验证
+var challengeRedirects = []string{"passport.weibo.com/visitor", "captcha", "verify", "/signin", "/login"}
+
+// challengePeek 识别验证页时最多读取的响应体字节数, 验证页的标题在页面开头
+const challengePeek = 16 << 10
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+// session 单个数据源的会话, 在多轮抓取之间保留 cookie
+type session struct {
+	name string
+	mu   sync.Mutex
+	jar  *cookiejar.Jar
+	// generation 每次丢弃 cookie 时加一, 同时失效的多个请求只由第一个刷新会话
+	generation int
+	// ready 是否已经加载持久化 cookie 或访问过预热页面
+	ready bool
+	// hosts 访问过的站点, 持久化时按站点导出 cookie
+	hosts map[string]bool
+}
+
+var (
+	sessions   = map[string]*session{}
+	sessionsMu sync.Mutex
+)
+
+// getSession 返回数据源的会话, 不存在时创建
+func getSession(name string) *session {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	s, ok := sessions[name]
+	if !ok {
+		s = &session{name: name}
+		s.reset()
+		sessions[name] = s
+	}
+	return s
+}
+
+// reset 丢弃现有 cookie, 重新写入配置中的初始 cookie, 调用方需持有锁或保证没有并发访问
+func (s *session) reset() {
+	s.jar, _ = cookiejar.New(nil)
+	s.generation++
+	s.ready = false
+	s.hosts = map[string]bool{}
+	cfg := config.SourceOf(s.name)
+	if cfg.LandingURL == "" || len(cfg.Cookies) == 0 {
+		return
+	}
+	landing, err := url.Parse(cfg.LandingURL)
+	if err != nil {
+		Logln(s.name, "landing_url parse err:", err)
+		return
+	}
+	var cookies []*http.Cookie
+	for name, value := range cfg.Cookies {
+		cookies = append(cookies, &http.Cookie{Name: name, Value: value, Domain: landing.Hostname(), Path: "/"})
+	}
+	s.jar.SetCookies(landing, cookies)
+	s.hosts[landing.Scheme+"://"+landing.Host] = true
+}
+
+// prepare 首次使用时加载持久化的 cookie, 没有则访问预热页面获取 cookie, ctx 取消时中止预热请求
+func (s *session) prepare(ctx context.Context, base http.RoundTripper) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warmUp(ctx, base)
+}
+
+// warmUp prepare 的实现, 调用方需持有锁, 预热期间同一数据源的其它请求等待
+func (s *session) warmUp(ctx context.Context, base http.RoundTripper) {
+	if s.ready {
+		return
+	}
+	s.ready = true
+	if s.load() {
+		return
+	}
+	landingURL := config.SourceOf(s.name).LandingURL
+	if landingURL == "" {
+		return
+	}
+	client := &http.Client{Jar: s.jar, Transport: base, Timeout: 10 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", landingURL, nil)
+	if err != nil {
+		Logln(s.name, "landing http.NewRequest err:", err)
+		return
+	}
+	req.Header.Set("User-Agent", userAgent)
+	res, err := client.Do(withSource(req, s.name))
+	if err != nil {
+		Logln(s.name, "landing client.Do err:", err)
+		return
+	}
+	err = res.Body.Close()
+	if err != nil {
+		Logln(s.name, "landing close err:", err)
+	}
+	s.hosts[req.URL.Scheme+"://"+req.URL.Host] = true
+	s.save()
+}
+
+// refresh 会话失效时丢弃 cookie 和持久化文件, 重新预热
+// generation 为发出失效请求时的会话版本, 会话已经被其它请求刷新过时直接返回, 使用新的 cookie 重试
+func (s *session) refresh(ctx context.Context, base http.RoundTripper, generation int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation != generation {
+		return
+	}
+	s.reset()
+	err := os.Remove(s.path())
+	if err != nil && !os.IsNotExist(err) {
+		Logln(s.name, "remove cookie file err:", err)
+	}
+	s.warmUp(ctx, base)
+}
+
+// apply 复制请求并带上会话 cookie, 按配置用 cookie 值替换查询参数, 同时返回 cookie 所属的会话版本
+func (s *session) apply(req *http.Request) (*http.Request, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req = withSource(req.Clone(req.Context()), s.name)
+	cookies := s.jar.Cookies(req.URL)
+	req.Header.Del("Cookie")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	params := config.SourceOf(s.name).CookieParams
+	if len(params) == 0 {
+		return req, s.generation
+	}
+	query := req.URL.Query()
+	for param, cookieName := range params {
+		for _, cookie := range cookies {
+			if cookie.Name == cookieName {
+				query.Set(param, cookie.Value)
+			}
+		}
+	}
+	req.URL.RawQuery = query.Encode()
+	return req, s.generation
+}
+
+// remember 保存响应中的 cookie 并持久化
+func (s *session) remember(req *http.Request, res *http.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cookies := res.Cookies()
+	if len(cookies) == 0 {
+		return
+	}
+	s.jar.SetCookies(req.URL, cookies)
+	s.hosts[req.URL.Scheme+"://"+req.URL.Host] = true
+	s.save()
+}
+
+func (s *session) path() string {
+	baseDir, _ := os.Getwd()
+	return filepath.Join(baseDir, cookieDir, s.name+".json")
+}
+
+// load 读取持久化的 cookie, 调用方需持有锁
+func (s *session) load() bool {
+	data, err := os.ReadFile(s.path())
+	if err != nil {
+		return false
+	}
+	var saved map[string]map[string]string
+	if err := json.Unmarshal(data, &saved); err != nil {
+		Logln(s.name, "cookie file json.Unmarshal err:", err)
+		return false
+	}
+	for host, values := range saved {
+		u, err := url.Parse(host)
+		if err != nil {
+			continue
+		}
+		var cookies []*http.Cookie
+		for name, value := range values {
+			cookies = append(cookies, &http.Cookie{Name: name, Value: value, Path: "/"})
+		}
+		s.jar.SetCookies(u, cookies)
+		s.hosts[host] = true
+	}
+	return len(saved) > 0
+}
+
+// save 按站点导出 cookie 写入文件, 调用方需持有锁
+func (s *session) save() {
+	saved := map[string]map[string]string{}
+	for host := range s.hosts {
+		u, err := url.Parse(host)
+		if err != nil {
+			continue
+		}
+		values := map[string]string{}
+		for _, cookie := range s.jar.Cookies(u) {
+			values[cookie.Name] = cookie.Value
+		}
+		if len(values) > 0 {
+			saved[host] = values
+		}
+	}
+	output, _ := json.Marshal(saved)
+	if err := os.MkdirAll(filepath.Dir(s.path()), 0755); err != nil {
+		Logln(s.name, "mkdir cookie dir err:", err)
+		return
+	}
+	if err := os.WriteFile(s.path(), output, 0600); err != nil {
+		Logln(s.name, "write cookie file err:", err)
+	}
+}
+
+// sessionTransport 为请求附加数据源会话和配置的请求头, 遇到认证失败或验证页时刷新会话并重试一次
+type sessionTransport struct {
+	session *session
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+// request 复制请求, 带上会话 cookie 和配置的请求头, 同时返回会话版本
+func (t *sessionTransport) request(req *http.Request) (*http.Request, int) {
+	req, generation := t.session.apply(req)
+	for key, value := range t.headers {
+		if strings.EqualFold(key, "Host") {
+			req.Host = value
+			continue
+		}
+		req.Header.Set(key, value)
+	}
+	return req, generation
+}
+
+// peekedBody 已读出开头部分的响应体
+type peekedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// challenge 判断响应是否表示会话失效或遇到验证页, 返回原因, 不是时返回空字符串
+// 按状态码、重定向地址和 HTML 页面的标题判断, 读取的响应体开头会放回 res.Body
+func (t *sessionTransport) challenge(res *http.Response) (string, error) {
+	if challengeStatus[res.StatusCode] {
+		return strconv.Itoa(res.StatusCode), nil
+	}
+	markers := config.SourceOf(t.session.name).ChallengeMarkers
+	if location := res.Header.Get("Location"); location != "" && res.StatusCode >= 300 && res.StatusCode < 400 {
+		for _, marker := range append(challengeRedirects, markers...) {
+			if marker != "" && strings.Contains(strings.ToLower(location), strings.ToLower(marker)) {
+				return "redirect " + location, nil
+			}
+		}
+		return "", nil
+	}
+	if res.StatusCode != http.StatusOK || !strings.Contains(res.Header.Get("Content-Type"), "html") {
+		return "", nil
+	}
+	head, err := io.ReadAll(io.LimitReader(res.Body, challengePeek))
+	if err != nil {
+		return "", err
+	}
+	res.Body = peekedBody{io.MultiReader(bytes.NewReader(head), res.Body), res.Body}
+	match := titlePattern.FindSubmatch(head)
+	if match == nil {
+		return "", nil
+	}
+	title := strings.TrimSpace(string(match[1]))
+	for _, marker := range append(challengeTitles, markers...) {
+		if marker != "" && strings.Contains(strings.ToLower(title), strings.ToLower(marker)) {
+			return "title " + title, nil
+		}
+	}
+	return "", nil
+}
+
+func (t *sessionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.session.prepare(req.Context(), t.base)
+	sessionReq, generation := t.request(req)
+	res, err := t.base.RoundTrip(sessionReq)
+	if err != nil {
+		return nil, err
+	}
+	reason, err := t.challenge(res)
+	if err != nil {
+		_ = res.Body.Close()
+		return nil, err
+	}
+	if reason == "" {
+		t.session.remember(req, res)
+		return res, nil
+	}
+	Logf("%s 会话失效(%s), 刷新 cookie 后重试\n", t.session.name, reason)
+	err = res.Body.Close()
+	if err != nil {
+		Logln(t.session.name, "close err:", err)
+	}
+	t.session.refresh(req.Context(), t.base, generation)
+	sessionReq, _ = t.request(req)
+	res, err = t.base.RoundTrip(sessionReq)
+	if err != nil {
+		return nil, err
+	}
+	t.session.remember(req, res)
+	return res, nil
+}
+
+// newClient 返回使用该数据源会话的 http.Client, 测试抓取时同时记录原始响应
+func (c Crawler) newClient(timeout time.Duration) *http.Client {
+	var transport http.RoundTripper = &sessionTransport{
+		session: getSession(c.crawlerName),
+		base:    politeTransport,
+		headers: c.settings().Headers,
+	}
+	if c.capture != nil {
+		transport = &captureTransport{base: transport, capture: c.capture}
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
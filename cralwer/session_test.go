@@ -0,0 +1,135 @@
+package cralwer
+
+import (
+	"context"
+	"fmt"
+	"goCrawlerHot/config"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sessionSite 模拟需要会话的站点: /landing 下发 sid cookie, /api 只接受最近一次下发的 sid,
+// 会话失效时按 mode 返回 403、200 的验证页或重定向到验证地址
+type sessionSite struct {
+	mode     string
+	landings int32
+	valid    int32
+}
+
+func (site *sessionSite) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	switch request.URL.Path {
+	case "/landing":
+		sid := atomic.AddInt32(&site.landings, 1)
+		atomic.StoreInt32(&site.valid, sid)
+		http.SetCookie(writer, &http.Cookie{Name: "sid", Value: fmt.Sprint(sid), Path: "/"})
+	case "/api":
+		cookie, err := request.Cookie("sid")
+		if err == nil && cookie.Value == fmt.Sprint(atomic.LoadInt32(&site.valid)) {
+			_, _ = io.WriteString(writer, `{"ok":true}`)
+			return
+		}
+		switch site.mode {
+		case "page":
+			writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = io.WriteString(writer, `<html><head><title>安全验证</title></head><body>请完成验证</body></html>`)
+		case "redirect":
+			http.Redirect(writer, request, "/captcha?from=api", http.StatusFound)
+		default:
+			writer.WriteHeader(http.StatusForbidden)
+		}
+	case "/captcha":
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(writer, `<title>captcha</title>`)
+	}
+}
+
+// sessionClient 返回使用名为 name 的新会话访问 site 的客户端, cookie 文件写入临时目录
+func sessionClient(t *testing.T, name, landingURL string) *http.Client {
+	t.Helper()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	old := config.Get()
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+		config.Set(old)
+		sessionsMu.Lock()
+		delete(sessions, name)
+		sessionsMu.Unlock()
+	})
+	cfg := config.Default()
+	cfg.Sources = map[string]config.Source{name: {LandingURL: landingURL}}
+	config.Set(cfg)
+	return &http.Client{Transport: &sessionTransport{session: getSession(name), base: http.DefaultTransport}}
+}
+
+func TestSessionRefresh(t *testing.T) {
+	for _, mode := range []string{"status", "page", "redirect"} {
+		t.Run(mode, func(t *testing.T) {
+			site := &sessionSite{mode: mode}
+			server := httptest.NewServer(site)
+			defer server.Close()
+			client := sessionClient(t, "TestSession"+mode, server.URL+"/landing")
+			get := func() string {
+				res, err := client.Get(server.URL + "/api")
+				if err != nil {
+					t.Error(err)
+					return ""
+				}
+				defer res.Body.Close()
+				body, _ := io.ReadAll(res.Body)
+				return string(body)
+			}
+			if body := get(); body != `{"ok":true}` {
+				t.Fatalf("first request = %q", body)
+			}
+			// 站点让会话失效后, 同时失效的请求只刷新一次会话
+			atomic.StoreInt32(&site.valid, 0)
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if body := get(); body != `{"ok":true}` {
+						t.Errorf("request after refresh = %q", body)
+					}
+				}()
+			}
+			wg.Wait()
+			if n := atomic.LoadInt32(&site.landings); n != 2 {
+				t.Errorf("landing visited %d times, want 2", n)
+			}
+		})
+	}
+}
+
+func TestSessionLandingCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if request.URL.Path == "/landing" {
+			select {
+			case <-request.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}))
+	defer server.Close()
+	client := sessionClient(t, "TestSessionCancel", server.URL+"/landing")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api", nil)
+	start := time.Now()
+	res, err := client.Do(req)
+	if err == nil {
+		_ = res.Body.Close()
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("landing request not cancelled, took %v", elapsed)
+	}
+}
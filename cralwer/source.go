@@ -0,0 +1,145 @@
+package cralwer
+
+import (
+	"fmt"
+	"goCrawlerHot/config"
+	"goCrawlerHot/i18n"
+	"golang.org/x/net/http/httpguts"
+	"sort"
+	"strings"
+)
+
+// SourceInfo 数据源的元信息, 数据源 ID 为爬虫方法名, 展示名称按语言单独维护
+type SourceInfo struct {
+	ID string `json:"id"`
+	// Names 各语言的展示名称, 键为 i18n 的语言标签
+	Names    map[string]string `json:"names"`
+	Homepage string            `json:"homepage"`
+	// URL 爬虫内置的请求地址, 可以用配置的 url 替换
+	URL string `json:"url"`
+	// Selectors HTML 数据源内置的 CSS 选择器, 可以用配置的 selectors 按键替换
+	Selectors map[string]string `json:"selectors,omitempty"`
+}
+
+// sourceInfos 全部数据源的元信息, 新增爬虫时在这里登记名称、请求地址和选择器
+var sourceInfos = map[string]SourceInfo{
+	"CrawlerWeiBo": {Names: map[string]string{"zh-CN": "新浪微博", "en": "Weibo"},
+		Homepage: "https://s.weibo.com/top/summary",
+		URL:      "https://m.weibo.cn/api/container/getIndex?containerid=106003type%3D25%26t%3D3%26disable_hot%3D1%26filter_type%3Drealtimehot&title=%E5%BE%AE%E5%8D%9A%E7%83%AD%E6%90%9C&extparam=seat%3D1%26pos%3D0_0%26dgr%3D0%26mi_cid%3D100103%26cate%3D10103%26filter_type%3Drealtimehot%26c_type%3D30%26display_time%3D1638445376%26pre_seqid%3D52252862&luicode=10000011&lfid=231583"},
+	"CrawlerZhiHu": {Names: map[string]string{"zh-CN": "知乎热榜", "en": "Zhihu Hot"},
+		Homepage: "https://www.zhihu.com/hot",
+		URL:      "https://www.zhihu.com/api/v3/feed/topstory/hot-lists/total?limit=50&desktop=true"},
+	"CrawlerTieBa": {Names: map[string]string{"zh-CN": "贴吧", "en": "Tieba"},
+		Homepage: "https://tieba.baidu.com/hottopic/browse/topicList",
+		URL:      "https://tieba.baidu.com/hottopic/browse/topicList"},
+	"CrawlerDouBan": {Names: map[string]string{"zh-CN": "豆瓣热榜", "en": "Douban"},
+		Homepage:  "https://www.douban.com/group/explore",
+		URL:       "https://www.douban.com/group/explore",
+		Selectors: map[string]string{"item": ".channel-item", "title": "h3 a"}},
+	"CrawlerTianYa": {Names: map[string]string{"zh-CN": "天涯热榜", "en": "Tianya"},
+		Homepage:  "http://bbs.tianya.cn/list.jsp?item=funinfo&grade=3&order=1",
+		URL:       "http://bbs.tianya.cn/hotArticle.jsp",
+		Selectors: map[string]string{"item": ".mt5 table tbody tr", "title": "td[class=td-title] a"}},
+	"CrawlerGithub": {Names: map[string]string{"zh-CN": "GitHub Trending", "en": "GitHub Trending"},
+		Homepage:  "https://github.com/trending",
+		URL:       "https://github.com/trending",
+		Selectors: map[string]string{"item": "article[class=Box-row]", "title": "h2 a", "description": "p", "heat": "span.float-sm-right"}},
+	"CrawlerWangYiYun": {Names: map[string]string{"zh-CN": "云音乐飙升榜", "en": "NetEase Music Rising"},
+		Homepage:  "https://music.163.com/#/discover/toplist?id=19723756",
+		URL:       "https://music.163.com/discover/toplist?id=19723756",
+		Selectors: map[string]string{"item": "div[id=song-list-pre-cache] ul[class=f-hide] li", "title": "a"}},
+	"CrawlerCSDN": {Names: map[string]string{"zh-CN": "CSDN热榜", "en": "CSDN Hot"},
+		Homepage: "https://blog.csdn.net/rank/list",
+		URL:      "https://blog.csdn.net/phoenix/web/blog/hot-rank?page={page}&pageSize=25&type="},
+	"CrawlerWeread": {Names: map[string]string{"zh-CN": "微信读书飙升榜", "en": "WeRead Rising"},
+		Homepage:  "https://weread.qq.com/web/category/rising",
+		URL:       "https://weread.qq.com/web/category/rising",
+		Selectors: map[string]string{"item": ".ranking_content_bookList li[class=wr_bookList_item]", "title": "p[class=wr_bookList_item_title]", "link": "a[class=wr_bookList_item_link]"}},
+	"Crawler52PoJie": {Names: map[string]string{"zh-CN": "吾爱破解", "en": "52PoJie"},
+		Homepage:  "https://www.52pojie.cn/forum.php?mod=guide&view=hot",
+		URL:       "https://www.52pojie.cn/forum.php?mod=guide&view=hot",
+		Selectors: map[string]string{"item": "#threadlist .bm_c tbody", "title": "tr th a[class=xst]", "link": "tr th a"}},
+	"CrawlerDouYin": {Names: map[string]string{"zh-CN": "抖音热榜", "en": "Douyin Hot"},
+		Homepage: "https://www.douyin.com/hot",
+		URL: "https://www.douyin.com/aweme/v1/web/hot/search/list/?device_platform=webapp&aid=6383&channel=channel_pc_w" +
+			"eb&detail_list=1&source=6&pc_client_type=1&version_code=170400&version_name=17.4.0&cookie_enabled=true&screen" +
+			"_width=1440&screen_height=900&browser_language=en&browser_platform=MacIntel&browser_name=Chrome&browser_" +
+			"version=107.0.0.0&browser_online=true&engine_name=Blink&engine_version=107.0.0.0&os_name=Mac+OS&os_version=" +
+			"10.15.7&cpu_core_num=8&device_memory=8&platform=PC&downlink=10&effective_type=4g&round_trip_time=100&webid" +
+			"=7168107943232308770&msToken=x872gxuQF3TKQoShjH0dOxcP5vMWOtp9vE3gAhYMVfvklclynZ5uOj8KsIw_WML0fzol" +
+			"EFqOw4NUSbVwMCIEqGNEs0tFx7hyogm9SI43HP4f__VTIc-mZgOCAjMj7A==&X-Bogus=DFSzswVOS1UANtnuS8c4f37TlqCw"},
+}
+
+// SourceInfos 按内置顺序返回全部数据源的元信息
+func SourceInfos() []SourceInfo {
+	infos := make([]SourceInfo, 0, len(allCrawler))
+	for _, name := range allCrawler {
+		info := sourceInfos[name]
+		info.ID = name
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// SourceName 返回数据源在 lang 下的展示名称, 没有该语言时使用默认语言, 未知的数据源返回 id 本身
+func SourceName(id, lang string) string {
+	info, ok := sourceInfos[id]
+	if !ok {
+		return id
+	}
+	if name, ok := info.Names[lang]; ok {
+		return name
+	}
+	if name, ok := info.Names[i18n.Default]; ok {
+		return name
+	}
+	return id
+}
+
+// settings 爬虫使用的数据源配置, 测试抓取时使用尚未保存的配置
+func (c Crawler) settings() config.Source {
+	if c.draft != nil {
+		return *c.draft
+	}
+	return config.SourceOf(c.crawlerName)
+}
+
+// endpoint 返回请求地址, 配置了 url 时使用配置的地址
+func (c Crawler) endpoint() string {
+	if u := c.settings().URL; u != "" {
+		return u
+	}
+	return sourceInfos[c.crawlerName].URL
+}
+
+// selector 返回键对应的 CSS 选择器, 配置了 selectors 时使用配置的选择器
+func (c Crawler) selector(key string) string {
+	if selector := c.settings().Selectors[key]; selector != "" {
+		return selector
+	}
+	return sourceInfos[c.crawlerName].Selectors[key]
+}
+
+// CheckSettings 检查数据源配置中的选择器是否为该数据源支持的键, 请求头是否合法
+func CheckSettings(name string, source config.Source) error {
+	info, ok := sourceInfos[name]
+	if !ok {
+		return fmt.Errorf("unknown source %q", name)
+	}
+	var unknown []string
+	for key := range source.Selectors {
+		if _, ok := info.Selectors[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("sources.%s.selectors: unknown keys %s", name, strings.Join(unknown, ", "))
+	}
+	for key, value := range source.Headers {
+		if !httpguts.ValidHeaderFieldName(key) || !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("sources.%s.headers: invalid header %q", name, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package cralwer
+
+import (
+	"encoding/json"
+	"goCrawlerHot/config"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store 抓取结果的存储, 多个实例使用同一个存储时共享数据
+type Store interface {
+	// Save 保存一轮抓取结果: 合并进最新结果(不覆盖更新的数据), 并追加到历史快照
+	Save(results []Result) error
+	// Latest 返回每个数据源最新的结果
+	Latest() ([]Result, error)
+	// Range 按时间顺序返回抓取时间在 [from, to] 之间的历史结果, source 为空时返回全部数据源
+	Range(from, to time.Time, source string) ([]Result, error)
+}
+
+var (
+	store   Store = NewFileStore("")
+	storeMu sync.RWMutex
+)
+
+// SetStore 替换使用的存储, 默认为工作目录下的 result.json 和 history 目录
+func SetStore(s Store) {
+	storeMu.Lock()
+	store = s
+	storeMu.Unlock()
+}
+
+func currentStore() Store {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return store
+}
+
+// saveHooks 结果保存成功后调用的函数
+var (
+	saveHooks   []func(results []Result)
+	saveHooksMu sync.RWMutex
+)
+
+// OnSave 注册结果保存成功后调用的函数, 如通知浏览器刷新
+func OnSave(fn func(results []Result)) {
+	saveHooksMu.Lock()
+	saveHooks = append(saveHooks, fn)
+	saveHooksMu.Unlock()
+}
+
+// ReadResults 读取每个启用的数据源最新的结果, 按数据源的默认顺序排列
+func ReadResults() ([]Result, error) {
+	all, err := currentStore().Latest()
+	var results []Result
+	for _, result := range all {
+		if result.Source == "" || Enabled(result.Source) {
+			results = append(results, result)
+		}
+	}
+	sortResults(results)
+	return results, err
+}
+
+// ReadAllResults 与 ReadResults 相同, 但包含停用的数据源
+func ReadAllResults() ([]Result, error) {
+	results, err := currentStore().Latest()
+	sortResults(results)
+	return results, err
+}
+
+// ReadHistory 按时间顺序返回抓取时间在 [from, to] 之间的历史结果, source 为空时返回全部数据源
+func ReadHistory(from, to time.Time, source string) ([]Result, error) {
+	return currentStore().Range(from, to, source)
+}
+
+// sortResults 按 DefaultOrder 排列, 未知数据源按名称排在最后
+func sortResults(results []Result) {
+	names := DefaultOrder()
+	order := map[string]int{}
+	for i, name := range names {
+		order[name] = i
+	}
+	position := func(r Result) int {
+		if i, ok := order[r.Source]; ok {
+			return i
+		}
+		return len(names)
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := position(results[i]), position(results[j])
+		if a != b {
+			return a < b
+		}
+		return a == len(names) && ResultKey(results[i]) < ResultKey(results[j])
+	})
+}
+
+// ResultKey 结果所属数据源的唯一键, 旧数据没有 source 字段时使用榜单名称
+func ResultKey(r Result) string {
+	if r.Source != "" {
+		return r.Source
+	}
+	return r.HotName
+}
+
+// FileStore 把最新结果写入 result.json, 历史快照按天写入 history 目录, 只适合单机部署
+type FileStore struct {
+	dir string
+	// mu 保护 result.json 的读写
+	mu sync.Mutex
+	// marksMu 保护 users 目录下已读记录和收藏的读写
+	marksMu sync.Mutex
+	// marksPruned 上一次清理过期用户的时间
+	marksPruned time.Time
+	lock        leaderLock
+}
+
+// NewFileStore 创建文件存储, dir 为空时使用工作目录
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+func (s *FileStore) baseDir() string {
+	if s.dir != "" {
+		return s.dir
+	}
+	baseDir, _ := os.Getwd()
+	return baseDir
+}
+
+func (s *FileStore) resultPath() string {
+	return filepath.Join(s.baseDir(), "result.json")
+}
+
+// Latest 读取 result.json, 文件不存在时返回空
+func (s *FileStore) Latest() ([]Result, error) {
+	data, err := os.ReadFile(s.resultPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	err = json.Unmarshal(data, &results)
+	return results, err
+}
+
+// Save 把新结果合并进 result.json, 并追加到历史快照
+func (s *FileStore) Save(updates []Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, err := s.Latest()
+	if err != nil {
+		// result.json 损坏时用新结果重建
+		Logln("read result err:", err)
+	}
+	output, _ := json.Marshal(mergeResults(old, updates))
+	err = config.WriteFile(s.resultPath(), output, 0644)
+	if err != nil {
+		return err
+	}
+	return s.appendHistory(updates)
+}
@@ -0,0 +1,226 @@
+package digest
+
+import (
+	"fmt"
+	"goCrawlerHot/cralwer"
+	"sort"
+	"time"
+)
+
+// 摘要周期
+const (
+	Day  = "day"
+	Week = "week"
+)
+
+// defaultInterval 无法从快照推算抓取间隔时使用的默认值, 与定时任务一致
+const defaultInterval = 10 * time.Minute
+
+// Topic 一个话题在统计周期内的表现
+type Topic struct {
+	Title string `json:"title"`
+	Href  string `json:"href"`
+	// Sources 出现过的热榜名称
+	Sources []string `json:"sources"`
+	// PeakRank 最高排名, 1 为榜首
+	PeakRank int `json:"peak_rank"`
+	// Appearances 出现在多少次快照中
+	Appearances int `json:"appearances"`
+	// OnBoard 估算的在榜时长, 出现次数乘以抓取间隔
+	OnBoard   time.Duration `json:"on_board"`
+	FirstSeen time.Time     `json:"first_seen"`
+	LastSeen  time.Time     `json:"last_seen"`
+}
+
+// SourceDigest 单个热榜的摘要
+type SourceDigest struct {
+	Source    string `json:"source"`
+	HotName   string `json:"hot_name"`
+	Snapshots int    `json:"snapshots"`
+	// Longest 在榜时间最长的话题
+	Longest []Topic `json:"longest"`
+	// Peak 最高排名最靠前的话题
+	Peak []Topic `json:"peak"`
+}
+
+// Digest 一个统计周期的摘要
+type Digest struct {
+	Period  string         `json:"period"`
+	Start   time.Time      `json:"start"`
+	End     time.Time      `json:"end"`
+	Sources []SourceDigest `json:"sources"`
+	// CrossSource 同时出现在多个热榜的话题, 按热榜数排序
+	CrossSource []Topic `json:"cross_source"`
+}
+
+// Title 摘要标题, 用作邮件主题和文件名
+func (d Digest) Title() string {
+	if d.Period == Week {
+		return fmt.Sprintf("热榜周报 %s ~ %s", d.Start.Format("2006-01-02"), d.End.Format("2006-01-02"))
+	}
+	return "热榜日报 " + d.Start.Format("2006-01-02")
+}
+
+// Range 返回包含 date 的统计周期: 日报为当天, 周报为 date 所在的周一到周日
+func Range(period string, date time.Time) (time.Time, time.Time, error) {
+	year, month, day := date.Date()
+	start := time.Date(year, month, day, 0, 0, 0, 0, date.Location())
+	switch period {
+	case Day:
+		return start, start.AddDate(0, 0, 1).Add(-time.Second), nil
+	case Week:
+		offset := (int(start.Weekday()) + 6) % 7
+		start = start.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 7).Add(-time.Second), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown period %q, use %s or %s", period, Day, Week)
+	}
+}
+
+// medianInterval 估算一个热榜的抓取间隔
+func medianInterval(times []time.Time) time.Duration {
+	if len(times) < 2 {
+		return defaultInterval
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	gaps := make([]time.Duration, 0, len(times)-1)
+	for i := 1; i < len(times); i++ {
+		gaps = append(gaps, times[i].Sub(times[i-1]))
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	return gaps[len(gaps)/2]
+}
+
+// Build 从历史快照统计 [start, end] 内的摘要, topN 为每个榜单保留的条数
+func Build(period string, start, end time.Time, topN int) (Digest, error) {
+	history, err := cralwer.ReadHistory(start, end, "")
+	if err != nil {
+		return Digest{}, err
+	}
+	return build(period, start, end, topN, history), nil
+}
+
+func build(period string, start, end time.Time, topN int, history []cralwer.Result) Digest {
+	type sourceStats struct {
+		source, hotName string
+		times           []time.Time
+		topics          map[string]*Topic
+		order           []string
+	}
+	var sourceOrder []string
+	stats := map[string]*sourceStats{}
+	for _, result := range history {
+		crawlerTime, err := time.ParseInLocation(cralwer.TimeLayout, result.CrawlerTime, time.Local)
+		if err != nil {
+			continue
+		}
+		key := result.Source
+		if key == "" {
+			key = result.HotName
+		}
+		s, ok := stats[key]
+		if !ok {
+			s = &sourceStats{source: result.Source, topics: map[string]*Topic{}}
+			stats[key] = s
+			sourceOrder = append(sourceOrder, key)
+		}
+		s.hotName = result.HotName
+		s.times = append(s.times, crawlerTime)
+		for index, item := range result.Content {
+			title := cralwer.ItemText(item, "title")
+			norm := cralwer.NormalizeTitle(title)
+			if norm == "" {
+				continue
+			}
+			topic, ok := s.topics[norm]
+			if !ok {
+				topic = &Topic{Title: title, PeakRank: index + 1, FirstSeen: crawlerTime}
+				s.topics[norm] = topic
+				s.order = append(s.order, norm)
+			}
+			topic.Href = cralwer.ItemText(item, "href")
+			topic.Appearances++
+			topic.LastSeen = crawlerTime
+			if index+1 < topic.PeakRank {
+				topic.PeakRank = index + 1
+			}
+		}
+	}
+
+	d := Digest{Period: period, Start: start, End: end}
+	cross := map[string]*Topic{}
+	var crossOrder []string
+	for _, key := range sourceOrder {
+		s := stats[key]
+		interval := medianInterval(s.times)
+		var topics []Topic
+		for _, norm := range s.order {
+			topic := s.topics[norm]
+			topic.OnBoard = time.Duration(topic.Appearances) * interval
+			topic.Sources = []string{s.hotName}
+			topics = append(topics, *topic)
+
+			c, ok := cross[norm]
+			if !ok {
+				copied := *topic
+				copied.Sources = nil
+				c = &copied
+				cross[norm] = c
+				crossOrder = append(crossOrder, norm)
+			}
+			c.Sources = append(c.Sources, s.hotName)
+			if topic.PeakRank < c.PeakRank {
+				c.PeakRank = topic.PeakRank
+			}
+			if topic.OnBoard > c.OnBoard {
+				c.OnBoard = topic.OnBoard
+			}
+		}
+		longest := append([]Topic(nil), topics...)
+		sort.SliceStable(longest, func(i, j int) bool {
+			if longest[i].OnBoard != longest[j].OnBoard {
+				return longest[i].OnBoard > longest[j].OnBoard
+			}
+			return longest[i].PeakRank < longest[j].PeakRank
+		})
+		peak := append([]Topic(nil), topics...)
+		sort.SliceStable(peak, func(i, j int) bool {
+			if peak[i].PeakRank != peak[j].PeakRank {
+				return peak[i].PeakRank < peak[j].PeakRank
+			}
+			return peak[i].OnBoard > peak[j].OnBoard
+		})
+		d.Sources = append(d.Sources, SourceDigest{
+			Source:    s.source,
+			HotName:   s.hotName,
+			Snapshots: len(s.times),
+			Longest:   limit(longest, topN),
+			Peak:      limit(peak, topN),
+		})
+	}
+
+	for _, norm := range crossOrder {
+		if len(cross[norm].Sources) > 1 {
+			d.CrossSource = append(d.CrossSource, *cross[norm])
+		}
+	}
+	sort.SliceStable(d.CrossSource, func(i, j int) bool {
+		a, b := d.CrossSource[i], d.CrossSource[j]
+		if len(a.Sources) != len(b.Sources) {
+			return len(a.Sources) > len(b.Sources)
+		}
+		if a.PeakRank != b.PeakRank {
+			return a.PeakRank < b.PeakRank
+		}
+		return a.OnBoard > b.OnBoard
+	})
+	d.CrossSource = limit(d.CrossSource, topN)
+	return d
+}
+
+func limit(topics []Topic, n int) []Topic {
+	if n > 0 && len(topics) > n {
+		return topics[:n]
+	}
+	return topics
+}
@@ -0,0 +1,135 @@
+package digest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"goCrawlerHot/config"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Notifier 投递生成好的摘要
+type Notifier interface {
+	Notify(d Digest, markdown, html string) error
+}
+
+// NewNotifier 按配置创建投递方式
+func NewNotifier(cfg config.Notifier) (Notifier, error) {
+	switch cfg.Type {
+	case "file":
+		return fileNotifier{dir: cfg.Dir}, nil
+	case "webhook":
+		return webhookNotifier{url: cfg.URL}, nil
+	case "smtp":
+		return smtpNotifier{cfg: cfg}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+	}
+}
+
+// fileName 摘要文件名, 如 day-2026-10-18
+func fileName(d Digest) string {
+	return d.Period + "-" + d.Start.Format("2006-01-02")
+}
+
+// fileNotifier 把 Markdown 和 HTML 写入目录
+type fileNotifier struct {
+	dir string
+}
+
+func (n fileNotifier) Notify(d Digest, markdown, html string) error {
+	err := os.MkdirAll(n.dir, 0755)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(n.dir, fileName(d)+".md"), []byte(markdown), 0644)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(n.dir, fileName(d)+".html"), []byte(html), 0644)
+}
+
+// webhookNotifier 以 JSON POST 摘要, 由接收方决定使用 Markdown 还是 HTML
+type webhookNotifier struct {
+	url string
+}
+
+func (n webhookNotifier) Notify(d Digest, markdown, html string) error {
+	body, _ := json.Marshal(map[string]string{"title": d.Title(), "markdown": markdown, "html": html})
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	err = res.Body.Close()
+	if err != nil {
+		fmt.Println("webhook close err:", err)
+	}
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook status code error: %d %s", res.StatusCode, res.Status)
+	}
+	return nil
+}
+
+// smtpNotifier 发送 HTML 邮件
+type smtpNotifier struct {
+	cfg config.Notifier
+}
+
+func (n smtpNotifier) Notify(d Digest, _, html string) error {
+	var msg bytes.Buffer
+	msg.WriteString("From: " + n.cfg.From + "\r\n")
+	msg.WriteString("To: " + strings.Join(n.cfg.To, ", ") + "\r\n")
+	msg.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", d.Title()) + "\r\n")
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(html))
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(n.cfg.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, host)
+	}
+	return smtp.SendMail(n.cfg.Addr, auth, n.cfg.From, n.cfg.To, msg.Bytes())
+}
+
+// Deliver 渲染摘要并通过全部配置的投递方式发送, 某个投递方式失败不影响其它投递方式
+func Deliver(d Digest, notifiers []config.Notifier) error {
+	html, err := HTML(d)
+	if err != nil {
+		return err
+	}
+	markdown := Markdown(d)
+	var errs []string
+	for _, cfg := range notifiers {
+		notifier, err := NewNotifier(cfg)
+		if err == nil {
+			err = notifier.Notify(d, markdown, html)
+		}
+		if err != nil {
+			errs = append(errs, cfg.Type+": "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("deliver digest: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package digest
+
+import (
+	"bytes"
+	"fmt"
+	"goCrawlerHot/cralwer"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// formatDuration 把在榜时长格式化为 x小时y分钟
+func formatDuration(d time.Duration) string {
+	hours := int(d.Hours())
+	minutes := int(d.Minutes()) % 60
+	if hours == 0 {
+		return fmt.Sprintf("%d分钟", minutes)
+	}
+	return fmt.Sprintf("%d小时%d分钟", hours, minutes)
+}
+
+// Markdown 把摘要渲染为 Markdown
+func Markdown(d Digest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n统计时间: %s ~ %s\n\n", d.Title(), d.Start.Format("2006-01-02 15:04"), d.End.Format("2006-01-02 15:04"))
+	b.WriteString("## 跨平台热点\n\n")
+	if len(d.CrossSource) == 0 {
+		b.WriteString("暂无同时登上多个热榜的话题\n\n")
+	} else {
+		b.WriteString("| # | 话题 | 热榜数 | 出现在 | 最高排名 |\n| --- | --- | --- | --- | --- |\n")
+		for i, topic := range d.CrossSource {
+			fmt.Fprintf(&b, "| %d | [%s](%s) | %d | %s | %d |\n", i+1, cralwer.EscapeMarkdown(topic.Title), topic.Href,
+				len(topic.Sources), cralwer.EscapeMarkdown(strings.Join(topic.Sources, "、")), topic.PeakRank)
+		}
+		b.WriteString("\n")
+	}
+	for _, source := range d.Sources {
+		fmt.Fprintf(&b, "## %s\n\n共 %d 次快照\n\n", source.HotName, source.Snapshots)
+		b.WriteString("### 在榜最久\n\n| # | 话题 | 在榜时长 | 最高排名 |\n| --- | --- | --- | --- |\n")
+		for i, topic := range source.Longest {
+			fmt.Fprintf(&b, "| %d | [%s](%s) | %s | %d |\n", i+1, cralwer.EscapeMarkdown(topic.Title), topic.Href,
+				formatDuration(topic.OnBoard), topic.PeakRank)
+		}
+		b.WriteString("\n### 最高排名\n\n| # | 话题 | 最高排名 | 在榜时长 |\n| --- | --- | --- | --- |\n")
+		for i, topic := range source.Peak {
+			fmt.Fprintf(&b, "| %d | [%s](%s) | %d | %s |\n", i+1, cralwer.EscapeMarkdown(topic.Title), topic.Href,
+				topic.PeakRank, formatDuration(topic.OnBoard))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// htmlTemplate 邮件使用的 HTML, 样式全部内联, 兼容常见邮件客户端
+var htmlTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"duration": formatDuration,
+	"addNum":   func(i int) int { return i + 1 },
+	"join":     strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="UTF-8"><title>{{.Title}}</title></head>
+<body style="font-family: sans-serif; color: #333; background: #f2f2f2; padding: 20px;">
+<div style="max-width: 800px; margin: 0 auto; background: #fff; padding: 20px;">
+  <h1 style="color: #c00;">{{.Title}}</h1>
+  <p style="color: #999;">统计时间: {{.Start.Format "2006-01-02 15:04"}} ~ {{.End.Format "2006-01-02 15:04"}}</p>
+  <h2>跨平台热点</h2>
+  {{if .CrossSource}}
+  <table style="border-collapse: collapse; width: 100%;">
+    <tr style="background: #fafafa;"><th align="left">#</th><th align="left">话题</th><th align="left">出现在</th><th align="left">最高排名</th></tr>
+    {{range $i, $t := .CrossSource}}
+    <tr style="border-top: 1px solid #eee;">
+      <td>{{addNum $i}}</td><td><a href="{{$t.Href}}">{{$t.Title}}</a></td><td>{{join $t.Sources "、"}}</td><td>{{$t.PeakRank}}</td>
+    </tr>
+    {{end}}
+  </table>
+  {{else}}
+  <p>暂无同时登上多个热榜的话题</p>
+  {{end}}
+  {{range .Sources}}
+  <h2>{{.HotName}} <small style="color: #999;">共 {{.Snapshots}} 次快照</small></h2>
+  <h3>在榜最久</h3>
+  <table style="border-collapse: collapse; width: 100%;">
+    {{range $i, $t := .Longest}}
+    <tr style="border-top: 1px solid #eee;">
+      <td>{{addNum $i}}</td><td><a href="{{$t.Href}}">{{$t.Title}}</a></td><td>{{duration $t.OnBoard}}</td><td>最高第 {{$t.PeakRank}} 名</td>
+    </tr>
+    {{end}}
+  </table>
+  <h3>最高排名</h3>
+  <table style="border-collapse: collapse; width: 100%;">
+    {{range $i, $t := .Peak}}
+    <tr style="border-top: 1px solid #eee;">
+      <td>{{addNum $i}}</td><td><a href="{{$t.Href}}">{{$t.Title}}</a></td><td>最高第 {{$t.PeakRank}} 名</td><td>{{duration $t.OnBoard}}</td>
+    </tr>
+    {{end}}
+  </table>
+  {{end}}
+</div>
+</body>
+</html>
+`))
+
+// HTML 把摘要渲染为 HTML 邮件
+func HTML(d Digest) (string, error) {
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, d)
+	return buf.String(), err
+}
@@ -0,0 +1,72 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"goCrawlerHot/config"
+	"time"
+)
+
+// nextRun 返回 now 之后下一次到达 at(HH:MM) 的时间
+func nextRun(now time.Time, at string) (time.Time, error) {
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next, nil
+}
+
+// recheckInterval 未开启或 digest.at 有误时重新检查配置的间隔
+const recheckInterval = time.Hour
+
+// Run 每天在配置的时间生成前一天的日报, 周一同时生成上一周的周报, ctx 取消后返回
+func Run(ctx context.Context) {
+	for {
+		cfg := config.Get().Digest
+		// 未开启或时间配置有误时定期检查配置, 以便配置更新后生效
+		wait := recheckInterval
+		next, err := nextRun(time.Now(), cfg.At)
+		if cfg.Daily || cfg.Weekly {
+			if err != nil {
+				fmt.Println("digest.at parse err:", err)
+			} else {
+				wait = time.Until(next)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		if (!cfg.Daily && !cfg.Weekly) || err != nil {
+			continue
+		}
+		yesterday := next.AddDate(0, 0, -1)
+		if cfg.Daily {
+			runPeriod(Day, yesterday, cfg)
+		}
+		if cfg.Weekly && next.Weekday() == time.Monday {
+			runPeriod(Week, yesterday, cfg)
+		}
+	}
+}
+
+// runPeriod 生成并投递包含 date 的周期的摘要
+func runPeriod(period string, date time.Time, cfg config.Digest) {
+	start, end, _ := Range(period, date)
+	d, err := Build(period, start, end, cfg.TopN)
+	if err != nil {
+		fmt.Println("digest Build err:", err)
+		return
+	}
+	err = Deliver(d, cfg.Notifiers)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("已生成", d.Title())
+}
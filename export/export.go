@@ -0,0 +1,203 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"goCrawlerHot/cralwer"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats 支持的导出格式
+var Formats = []string{"csv", "xlsx", "json", "md"}
+
+// contentTypes 各格式下载时使用的 Content-Type
+var contentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"json": "application/json; charset=utf-8",
+	"md":   "text/markdown; charset=utf-8",
+}
+
+// utf8BOM 写在 CSV 开头, 让 Excel 按 UTF-8 打开
+const utf8BOM = "\xef\xbb\xbf"
+
+// header 表格类格式的列
+var header = []string{"source", "hot_name", "rank", "title", "href", "heat", "crawler_time"}
+
+// ContentType 返回格式对应的 Content-Type, 不支持的格式返回空字符串
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// Write 按格式把热榜写入 w
+func Write(w io.Writer, format string, results []cralwer.Result) error {
+	switch format {
+	case "csv":
+		return writeCSV(w, results)
+	case "xlsx":
+		return writeXLSX(w, results)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	case "md":
+		return writeMarkdown(w, results)
+	default:
+		return fmt.Errorf("unknown format %q, supported: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// Filter 只保留指定数据源, source 可以是爬虫名称或热榜名称, 为空时返回全部
+func Filter(results []cralwer.Result, source string) []cralwer.Result {
+	if source == "" {
+		return results
+	}
+	var filtered []cralwer.Result
+	for _, result := range results {
+		if result.Source == source || result.HotName == source {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+// heatLabel 展示用的热度, 优先使用原始文本
+func heatLabel(item map[string]interface{}) string {
+	if label := cralwer.ItemText(item, "heat_label"); label != "" {
+		return label
+	}
+	return cralwer.ItemText(item, "heat")
+}
+
+// rows 把热榜展开为表格行, 与 header 的列一一对应
+func rows(results []cralwer.Result) [][]string {
+	var table [][]string
+	for _, result := range results {
+		for index, item := range result.Content {
+			table = append(table, []string{result.Source, result.HotName, strconv.Itoa(index + 1),
+				cralwer.ItemText(item, "title"), cralwer.ItemText(item, "href"), cralwer.ItemText(item, "heat"), result.CrawlerTime})
+		}
+	}
+	return table
+}
+
+// formulaPrefixes Excel 等表格软件打开 CSV 时把以这些字符开头的单元格当作公式
+const formulaPrefixes = "=+-@\t\r"
+
+// csvCell 在可能被当作公式的单元格前加上单引号, 避免抓取的标题在表格软件中作为公式执行, 数字不受影响
+func csvCell(value string) string {
+	if value == "" || !strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return value
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return value
+	}
+	return "'" + value
+}
+
+func writeCSV(w io.Writer, results []cralwer.Result) error {
+	_, err := io.WriteString(w, utf8BOM)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows(results) {
+		for i := range row {
+			row[i] = csvCell(row[i])
+		}
+		err = writer.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// hasHeat 热榜中是否有条目带热度
+func hasHeat(result cralwer.Result) bool {
+	for _, item := range result.Content {
+		if heatLabel(item) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func writeMarkdown(w io.Writer, results []cralwer.Result) error {
+	for _, result := range results {
+		heat := hasHeat(result)
+		tableHeader := "| # | 标题 |\n| --- | --- |\n"
+		if heat {
+			tableHeader = "| # | 标题 | 热度 |\n| --- | --- | --- |\n"
+		}
+		_, err := fmt.Fprintf(w, "## %s\n\n抓取时间: %s\n\n%s", result.HotName, result.CrawlerTime, tableHeader)
+		if err != nil {
+			return err
+		}
+		for index, item := range result.Content {
+			line := fmt.Sprintf("| %d | [%s](%s) |", index+1, cralwer.EscapeMarkdown(cralwer.ItemText(item, "title")), cralwer.ItemText(item, "href"))
+			if heat {
+				line += " " + cralwer.EscapeMarkdown(heatLabel(item)) + " |"
+			}
+			_, err = fmt.Fprintln(w, line)
+			if err != nil {
+				return err
+			}
+		}
+		_, err = fmt.Fprintln(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTime 解析时间, 支持 2006-01-02、2006-01-02 15:04 和 2006-01-02 15:04:05, 只有日期且 endOfDay 为 true 时取当天最后一秒
+func parseTime(value string, endOfDay bool) (time.Time, error) {
+	value = strings.Replace(strings.TrimSpace(value), "T", " ", 1)
+	for _, layout := range []string{cralwer.TimeLayout, "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("invalid time %q, use 2006-01-02 or 2006-01-02 15:04:05", value)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, nil
+}
+
+// Load 读取要导出的热榜, from 和 to 都为空时导出当前快照, 否则导出时间段内的历史快照, 缺省的 from 表示不限开始时间, 缺省的 to 表示到现在
+func Load(source, from, to string) ([]cralwer.Result, error) {
+	if from == "" && to == "" {
+		results, err := cralwer.ReadResults()
+		return Filter(results, source), err
+	}
+	start, end := time.Time{}, time.Now()
+	var err error
+	if from != "" {
+		start, err = parseTime(from, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if to != "" {
+		end, err = parseTime(to, true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cralwer.ReadHistory(start, end, source)
+}
@@ -0,0 +1,201 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"goCrawlerHot/cralwer"
+	"io"
+	"strings"
+	"testing"
+)
+
+// sample 两个数据源的小热榜, 标题包含公式、竖线、换行和 XML 特殊字符
+var sample = []cralwer.Result{
+	{
+		Source:      "CrawlerZhiHu",
+		HotName:     "知乎热榜",
+		CrawlerTime: "2026-10-19 10:00:00",
+		Content: []map[string]interface{}{
+			{"title": "=HYPERLINK(\"http://evil\")", "href": "https://www.zhihu.com/question/1", "heat": 1.5e7, "heat_label": "1500 万热度"},
+			{"title": "a | b\r\nc", "href": "https://www.zhihu.com/question/2", "heat": -3.0},
+		},
+	},
+	{
+		Source:      "CrawlerGithub",
+		HotName:     "GitHub: [trending]",
+		CrawlerTime: "2026-10-19 10:05:00",
+		Content: []map[string]interface{}{
+			{"title": "<tom & jerry>", "href": "https://github.com/a/b"},
+			{"title": "@SUM(1+1)", "href": "https://github.com/c/d"},
+		},
+	},
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{"普通标题", "普通标题"},
+		{"=1+2", "'=1+2"},
+		{"+cmd|' /C calc'!A0", "'+cmd|' /C calc'!A0"},
+		{"-2+3", "'-2+3"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"-3", "-3"},
+		{"+1.5", "+1.5"},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := csvCell(tt.value); got != tt.want {
+			t.Errorf("csvCell(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "csv", sample); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte(utf8BOM)) {
+		t.Fatal("missing UTF-8 BOM")
+	}
+	records, err := csv.NewReader(bytes.NewReader(buf.Bytes()[len(utf8BOM):])).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		header,
+		{"CrawlerZhiHu", "知乎热榜", "1", "'=HYPERLINK(\"http://evil\")", "https://www.zhihu.com/question/1", "15000000", "2026-10-19 10:00:00"},
+		{"CrawlerZhiHu", "知乎热榜", "2", "a | b\nc", "https://www.zhihu.com/question/2", "-3", "2026-10-19 10:00:00"},
+		{"CrawlerGithub", "GitHub: [trending]", "1", "<tom & jerry>", "https://github.com/a/b", "", "2026-10-19 10:05:00"},
+		{"CrawlerGithub", "GitHub: [trending]", "2", "'@SUM(1+1)", "https://github.com/c/d", "", "2026-10-19 10:05:00"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d", len(records), len(want))
+	}
+	for i := range want {
+		if strings.Join(records[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("record %d = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "md", sample); err != nil {
+		t.Fatal(err)
+	}
+	output := buf.String()
+	for _, want := range []string{
+		"## 知乎热榜\n\n抓取时间: 2026-10-19 10:00:00\n\n| # | 标题 | 热度 |\n| --- | --- | --- |\n",
+		"| 1 | [=HYPERLINK(\"http://evil\")](https://www.zhihu.com/question/1) | 1500 万热度 |\n",
+		"| 2 | [a \\| b c](https://www.zhihu.com/question/2) | -3 |\n",
+		// 没有热度的热榜不输出热度列
+		"| # | 标题 |\n| --- | --- |\n| 1 | [<tom & jerry>](https://github.com/a/b) |\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("markdown missing %q in:\n%s", want, output)
+		}
+	}
+}
+
+// readZip 读取 xlsx 中的全部文件
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name] = string(content)
+	}
+	return files
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "xlsx", sample); err != nil {
+		t.Fatal(err)
+	}
+	files := readZip(t, buf.Bytes())
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels",
+		"xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+	tests := []struct {
+		file string
+		want string
+	}{
+		// 工作表名称去掉不允许的字符
+		{"xl/workbook.xml", `<sheet name="知乎热榜" sheetId="1" r:id="rId1"/>`},
+		{"xl/workbook.xml", `<sheet name="GitHub_ _trending_" sheetId="2" r:id="rId2"/>`},
+		{"[Content_Types].xml", `<Override PartName="/xl/worksheets/sheet2.xml"`},
+		{"xl/_rels/workbook.xml.rels", `Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"`},
+		{"xl/worksheets/sheet1.xml", `<c r="A1" t="inlineStr"><is><t xml:space="preserve">source</t></is></c>`},
+		// 排名和数字热度按数字写入
+		{"xl/worksheets/sheet1.xml", `<c r="C2"><v>1</v></c>`},
+		{"xl/worksheets/sheet1.xml", `<c r="F2"><v>15000000</v></c>`},
+		// 内联字符串不会作为公式执行, 原样保留
+		{"xl/worksheets/sheet1.xml", `<c r="D2" t="inlineStr"><is><t xml:space="preserve">=HYPERLINK(&#34;http://evil&#34;)</t></is></c>`},
+		{"xl/worksheets/sheet2.xml", `<c r="D2" t="inlineStr"><is><t xml:space="preserve">&lt;tom &amp; jerry&gt;</t></is></c>`},
+		{"xl/worksheets/sheet2.xml", `<c r="F2" t="inlineStr"><is><t xml:space="preserve"></t></is></c>`},
+	}
+	for _, tt := range tests {
+		if !strings.Contains(files[tt.file], tt.want) {
+			t.Errorf("%s missing %s", tt.file, tt.want)
+		}
+	}
+}
+
+func TestWriteXLSXEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "xlsx", nil); err != nil {
+		t.Fatal(err)
+	}
+	files := readZip(t, buf.Bytes())
+	if !strings.Contains(files["xl/workbook.xml"], `<sheet name="Sheet1"`) {
+		t.Error("empty export should contain Sheet1")
+	}
+}
+
+func TestSheetName(t *testing.T) {
+	tests := []struct {
+		hotName, source string
+		want            string
+	}{
+		{"微博热搜", "CrawlerWeiBo", "微博热搜"},
+		{"", "CrawlerWeiBo", "CrawlerWeiBo"},
+		{"a/b\\c?d*e:f", "", "a_b_c_d_e_f"},
+		{strings.Repeat("长", 40), "", strings.Repeat("长", 31)},
+		{"", "", "Sheet"},
+	}
+	for _, tt := range tests {
+		if got := sheetName(tt.hotName, tt.source); got != tt.want {
+			t.Errorf("sheetName(%q, %q) = %q, want %q", tt.hotName, tt.source, got, tt.want)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for index, want := range map[int]string{0: "A", 6: "G", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(index); got != want {
+			t.Errorf("columnName(%d) = %q, want %q", index, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"goCrawlerHot/cralwer"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// writeXLSX 生成只包含内联字符串的最小 xlsx 文件, 每个数据源一个工作表
+func writeXLSX(w io.Writer, results []cralwer.Result) error {
+	var names []string
+	sheets := map[string][]cralwer.Result{}
+	for _, result := range results {
+		name := sheetName(result.HotName, result.Source)
+		if _, ok := sheets[name]; !ok {
+			names = append(names, name)
+		}
+		sheets[name] = append(sheets[name], result)
+	}
+	if len(names) == 0 {
+		names = []string{"Sheet1"}
+	}
+
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", contentTypesXML(len(names))},
+		{"_rels/.rels", rootRelsXML},
+		{"xl/workbook.xml", workbookXML(names)},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML(len(names))},
+	}
+	for index, name := range names {
+		files = append(files, struct {
+			name    string
+			content string
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", index+1), sheetXML(rows(sheets[name]))})
+	}
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(writer, file.content)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// sheetName 生成合法的工作表名称: 不超过 31 个字符, 不含 []:*?/\
+func sheetName(hotName, source string) string {
+	name := hotName
+	if name == "" {
+		name = source
+	}
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	runes := []rune(name)
+	if len(runes) > 31 {
+		runes = runes[:31]
+	}
+	if len(runes) == 0 {
+		return "Sheet"
+	}
+	return string(runes)
+}
+
+// escapeXML 转义文本并去掉 XML 不允许的控制字符
+func escapeXML(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// columnName 返回从 0 开始的列号对应的列名, 如 0 -> A
+func columnName(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+func sheetXML(table [][]string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for rowIndex, row := range append([][]string{header}, table...) {
+		b.WriteString(`<row r="` + strconv.Itoa(rowIndex+1) + `">`)
+		for colIndex, value := range row {
+			ref := columnName(colIndex) + strconv.Itoa(rowIndex+1)
+			// 排名列和数字热度按数字写入, 方便在 Excel 中排序
+			if rowIndex > 0 && (header[colIndex] == "rank" || header[colIndex] == "heat") {
+				if _, err := strconv.ParseFloat(value, 64); err == nil {
+					b.WriteString(`<c r="` + ref + `"><v>` + value + `</v></c>`)
+					continue
+				}
+			}
+			b.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">` + escapeXML(value) + `</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.String()
+}
+
+func contentTypesXML(sheets int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	for i := 1; i <= sheets; i++ {
+		b.WriteString(fmt.Sprintf(`<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i))
+	}
+	b.WriteString(`</Types>`)
+	return b.String()
+}
+
+const rootRelsXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+func workbookXML(names []string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, name := range names {
+		b.WriteString(fmt.Sprintf(`<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escapeXML(name), i+1, i+1))
+	}
+	b.WriteString(`</sheets></workbook>`)
+	return b.String()
+}
+
+func workbookRelsXML(sheets int) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= sheets; i++ {
+		b.WriteString(fmt.Sprintf(`<Relationship Id="rId%d" `+
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" `+
+			`Target="worksheets/sheet%d.xml"/>`, i, i))
+	}
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
@@ -0,0 +1,83 @@
+// 离线缓存: 页面和热榜接口优先请求网络, 失败时使用上次缓存的快照; layui 等静态资源优先使用缓存
+
+// 修改缓存内容或策略时递增版本, 旧版本的缓存在 activate 时删除
+var CACHE = 'hot-v1';
+
+// 安装时预先缓存的页面和静态资源
+var PRECACHE = ['/', '/lite', '/layui/layui.js', '/layui/css/layui.css', '/manifest.webmanifest', '/icon.svg'];
+
+self.addEventListener('install', function (event) {
+    event.waitUntil(caches.open(CACHE).then(function (cache) {
+        return cache.addAll(PRECACHE);
+    }).then(function () {
+        return self.skipWaiting();
+    }));
+});
+
+self.addEventListener('activate', function (event) {
+    event.waitUntil(caches.keys().then(function (names) {
+        return Promise.all(names.filter(function (name) {
+            return name !== CACHE;
+        }).map(function (name) {
+            return caches.delete(name);
+        }));
+    }).then(function () {
+        return self.clients.claim();
+    }));
+});
+
+// networkFirst 请求成功时更新缓存, 离线时返回缓存; 页面在没有完全相同的地址时忽略查询参数再找一次
+function networkFirst(request, ignoreSearch) {
+    return fetch(request).then(function (response) {
+        if (response.ok) {
+            var copy = response.clone();
+            caches.open(CACHE).then(function (cache) {
+                cache.put(request, copy);
+            });
+        }
+        return response;
+    }).catch(function (err) {
+        return caches.match(request).then(function (cached) {
+            return cached || (ignoreSearch ? caches.match(request, {ignoreSearch: true}) : undefined);
+        }).then(function (cached) {
+            if (!cached) {
+                throw err;
+            }
+            return cached;
+        });
+    });
+}
+
+function cacheFirst(request) {
+    return caches.match(request).then(function (cached) {
+        return cached || fetch(request).then(function (response) {
+            if (response.ok) {
+                var copy = response.clone();
+                caches.open(CACHE).then(function (cache) {
+                    cache.put(request, copy);
+                });
+            }
+            return response;
+        });
+    });
+}
+
+self.addEventListener('fetch', function (event) {
+    var request = event.request;
+    var url = new URL(request.url);
+    if (request.method !== 'GET' || url.origin !== self.location.origin) {
+        return;
+    }
+    if (url.pathname.indexOf('/layui/') === 0 || url.pathname === '/manifest.webmanifest' || url.pathname === '/icon.svg') {
+        event.respondWith(cacheFirst(request));
+        return;
+    }
+    if (request.mode === 'navigate') {
+        event.respondWith(networkFirst(request, true));
+        return;
+    }
+    // 事件流、导出和其它接口不缓存
+    if (url.pathname === '/api/hot') {
+        event.respondWith(networkFirst(request, false));
+    }
+});
@@ -0,0 +1,187 @@
+package i18n
+
+// catalogs 页面文案, 新增文案时两种语言都要补上
+var catalogs = map[string]map[string]string{
+	"zh-CN": {
+		"title":             "今日热榜",
+		"lang.switch":       "English",
+		"auth.login":        "登录",
+		"auth.logout":       "退出",
+		"lang.other":        "en",
+		"no_data":           "暂无数据",
+		"nav.rank":          "全平台热榜",
+		"nav.boards":        "各平台热榜",
+		"prefs":             "设置",
+		"prefs.title":       "页面设置",
+		"prefs.layout":      "布局",
+		"prefs.save":        "保存",
+		"prefs.reset":       "恢复默认",
+		"layout.tabs":       "标签页",
+		"layout.grid":       "网格",
+		"refresh.current":   "刷新当前",
+		"refresh.all":       "全部刷新",
+		"refresh.token":     "请输入管理令牌",
+		"refresh.result":    "刷新结果",
+		"refresh.failed":    "失败",
+		"refresh.error":     "刷新失败",
+		"refresh.items":     " 条",
+		"export.current":    "导出当前",
+		"export.all":        "导出全部",
+		"sort.heat":         "按热度排序",
+		"sort.rank":         "按榜单排序",
+		"trend.item":        "排名变化",
+		"trend.source":      "新增趋势",
+		"trend.hourly":      "每小时新上榜",
+		"chart.rank":        "排名",
+		"chart.heat":        "热度",
+		"load.error":        "加载失败",
+		"rank.by_rank":      "按排名",
+		"rank.by_zscore":    "按热度标准分",
+		"rank.topic":        "话题",
+		"rank.sources":      "出现在",
+		"rank.score":        "分数",
+		"rank.generated":    "生成时间",
+		"board.updated_at":  "更新于 %s",
+		"lite":              "精简版",
+		"lite.full":         "完整版",
+		"lite.loading":      "加载中...",
+		"offline":           "离线中, 显示的是上次保存的热榜",
+		"bookmarks":         "收藏",
+		"bookmarks.empty":   "还没有收藏",
+		"bookmark.add":      "收藏",
+		"bookmark.remove":   "取消收藏",
+		"mark_read":         "全部标为已读",
+		"prefs.hide_read":   "隐藏已读",
+		"prefs.sensitive":   "显示敏感内容",
+		"cat":               "分类",
+		"cat.all":           "全部",
+		"cat.politics":      "时政",
+		"cat.finance":       "财经",
+		"cat.tech":          "科技",
+		"cat.entertainment": "娱乐",
+		"cat.sports":        "体育",
+		"cat.games":         "游戏",
+		"admin.sensitive":   "敏感",
+		"nav.admin":         "数据源管理",
+		"admin.source":      "数据源",
+		"admin.enabled":     "启用",
+		"admin.interval":    "间隔(分钟)",
+		"admin.crawled":     "最近抓取",
+		"admin.count":       "条目数",
+		"admin.filtered":    "已过滤",
+		"admin.last_error":  "最近错误",
+		"admin.actions":     "操作",
+		"admin.edit":        "编辑",
+		"admin.test":        "测试抓取",
+		"admin.save":        "保存",
+		"admin.saved":       "已保存",
+		"admin.url":         "请求地址",
+		"admin.headers":     "请求头",
+		"admin.headers.tip": "每行一个, 如 Referer: https://example.com/",
+		"admin.selectors":   "选择器",
+		"admin.default":     "留空使用内置值",
+		"admin.items":       "解析结果",
+		"admin.raw":         "原始响应",
+		"admin.duration":    "耗时 %s 秒",
+		"admin.truncated":   "响应过长, 已截断",
+		"admin.failures":    "最近失败",
+		"admin.no_failures": "没有失败记录",
+		"admin.time":        "时间",
+		"admin.error":       "错误",
+		"admin.filter_log":  "最近过滤",
+		"admin.no_filtered": "没有过滤记录",
+		"admin.title":       "标题",
+		"admin.reason":      "原因",
+	},
+	"en": {
+		"title":             "Today's Hot",
+		"lang.switch":       "中文",
+		"auth.login":        "Log in",
+		"auth.logout":       "Log out",
+		"lang.other":        "zh-CN",
+		"no_data":           "No data",
+		"nav.rank":          "All platforms",
+		"nav.boards":        "By platform",
+		"prefs":             "Settings",
+		"prefs.title":       "Page settings",
+		"prefs.layout":      "Layout",
+		"prefs.save":        "Save",
+		"prefs.reset":       "Reset",
+		"layout.tabs":       "Tabs",
+		"layout.grid":       "Grid",
+		"refresh.current":   "Refresh this",
+		"refresh.all":       "Refresh all",
+		"refresh.token":     "Enter the admin token",
+		"refresh.result":    "Refresh results",
+		"refresh.failed":    "failed",
+		"refresh.error":     "Refresh failed",
+		"refresh.items":     " items",
+		"export.current":    "Export this",
+		"export.all":        "Export all",
+		"sort.heat":         "Sort by heat",
+		"sort.rank":         "Sort by rank",
+		"trend.item":        "Rank history",
+		"trend.source":      "New entries",
+		"trend.hourly":      "new entries per hour",
+		"chart.rank":        "Rank",
+		"chart.heat":        "Heat",
+		"load.error":        "Failed to load",
+		"rank.by_rank":      "By rank",
+		"rank.by_zscore":    "By heat z-score",
+		"rank.topic":        "Topic",
+		"rank.sources":      "Appears on",
+		"rank.score":        "Score",
+		"rank.generated":    "Generated at",
+		"board.updated_at":  "Updated %s",
+		"lite":              "Lite",
+		"lite.full":         "Full version",
+		"lite.loading":      "Loading...",
+		"offline":           "Offline, showing the last saved boards",
+		"bookmarks":         "Saved",
+		"bookmarks.empty":   "Nothing saved yet",
+		"bookmark.add":      "Save",
+		"bookmark.remove":   "Remove from saved",
+		"mark_read":         "Mark all read",
+		"prefs.hide_read":   "Hide read items",
+		"prefs.sensitive":   "Show sensitive items",
+		"cat":               "Category",
+		"cat.all":           "All",
+		"cat.politics":      "Politics",
+		"cat.finance":       "Finance",
+		"cat.tech":          "Tech",
+		"cat.entertainment": "Entertainment",
+		"cat.sports":        "Sports",
+		"cat.games":         "Games",
+		"admin.sensitive":   "Sensitive",
+		"nav.admin":         "Sources",
+		"admin.source":      "Source",
+		"admin.enabled":     "Enabled",
+		"admin.interval":    "Interval (min)",
+		"admin.crawled":     "Last crawl",
+		"admin.count":       "Items",
+		"admin.filtered":    "Filtered",
+		"admin.last_error":  "Last error",
+		"admin.actions":     "Actions",
+		"admin.edit":        "Edit",
+		"admin.test":        "Test crawl",
+		"admin.save":        "Save",
+		"admin.saved":       "Saved",
+		"admin.url":         "URL",
+		"admin.headers":     "Headers",
+		"admin.headers.tip": "One per line, e.g. Referer: https://example.com/",
+		"admin.selectors":   "Selectors",
+		"admin.default":     "Leave empty to use the built-in value",
+		"admin.items":       "Parsed items",
+		"admin.raw":         "Raw response",
+		"admin.duration":    "Took %s s",
+		"admin.truncated":   "Response too long, truncated",
+		"admin.failures":    "Recent failures",
+		"admin.no_failures": "No failures",
+		"admin.time":        "Time",
+		"admin.error":       "Error",
+		"admin.filter_log":  "Recently filtered",
+		"admin.no_filtered": "Nothing filtered",
+		"admin.title":       "Title",
+		"admin.reason":      "Reason",
+	},
+}
@@ -0,0 +1,128 @@
+// Package i18n 页面文案的多语言目录、语言协商和时间格式
+package i18n
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default 默认语言, 也是存储中 hot_name 使用的语言
+const Default = "zh-CN"
+
+// Languages 支持的语言
+var Languages = []string{"zh-CN", "en"}
+
+// Cookie 保存页面语言的 cookie, 由页面上的语言切换链接写入
+const Cookie = "hot_lang"
+
+// timeLayouts 各语言的时间格式
+var timeLayouts = map[string]string{
+	"zh-CN": "2006年1月2日 15:04:05",
+	"en":    "Jan 2, 2006 3:04:05 PM",
+}
+
+// Supported 返回与 tag 对应的受支持语言, 如 en-US 对应 en, zh、zh-Hans 对应 zh-CN, 不支持时返回空字符串
+func Supported(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for _, lang := range Languages {
+		if tag == strings.ToLower(lang) {
+			return lang
+		}
+	}
+	primary := strings.SplitN(tag, "-", 2)[0]
+	for _, lang := range Languages {
+		if primary == strings.ToLower(strings.SplitN(lang, "-", 2)[0]) {
+			return lang
+		}
+	}
+	return ""
+}
+
+// Negotiate 确定请求使用的语言: 依次为查询参数 lang、cookie 和 Accept-Language, 都没有时使用默认语言。
+// 查询参数中的语言会写入 cookie, 之后的页面沿用该语言
+func Negotiate(writer http.ResponseWriter, request *http.Request) string {
+	if lang := Supported(request.URL.Query().Get("lang")); lang != "" {
+		http.SetCookie(writer, &http.Cookie{Name: Cookie, Value: lang, Path: "/", MaxAge: 365 * 24 * 3600})
+		return lang
+	}
+	if cookie, err := request.Cookie(Cookie); err == nil {
+		if lang := Supported(cookie.Value); lang != "" {
+			return lang
+		}
+	}
+	return acceptLanguage(request.Header.Get("Accept-Language"))
+}
+
+// acceptLanguage 按 q 值从高到低选出第一个受支持的语言
+func acceptLanguage(header string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := weighted{tag: strings.TrimSpace(fields[0]), q: 1}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err == nil {
+					tag.q = q
+				}
+			}
+		}
+		if tag.tag != "" && tag.q > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+	for _, tag := range tags {
+		if lang := Supported(tag.tag); lang != "" {
+			return lang
+		}
+	}
+	return Default
+}
+
+// T 返回 key 在 lang 下的文案, 有 args 时按 fmt.Sprintf 格式化, 缺少翻译时依次使用默认语言和 key 本身
+func T(lang, key string, args ...interface{}) string {
+	message, ok := catalogs[lang][key]
+	if !ok {
+		message, ok = catalogs[Default][key]
+	}
+	if !ok {
+		message = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Messages 返回 lang 的全部文案, 供页面脚本使用, 缺少的翻译用默认语言补齐
+func Messages(lang string) map[string]string {
+	messages := map[string]string{}
+	for key, message := range catalogs[Default] {
+		messages[key] = message
+	}
+	for key, message := range catalogs[lang] {
+		messages[key] = message
+	}
+	return messages
+}
+
+// FormatTime 按 lang 的习惯格式化时间
+func FormatTime(lang string, t time.Time) string {
+	layout, ok := timeLayouts[lang]
+	if !ok {
+		layout = timeLayouts[Default]
+	}
+	return t.Format(layout)
+}
@@ -0,0 +1,185 @@
+// Package live 通过 Server-Sent Events 把热榜的变化推送给浏览器
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goCrawlerHot/cralwer"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// keepAliveInterval 没有事件时发送注释行的间隔, 避免代理断开空闲连接
+const keepAliveInterval = 30 * time.Second
+
+// Event 一个热榜有了新的抓取结果
+type Event struct {
+	Source      string                   `json:"source"`
+	HotName     string                   `json:"hot_name"`
+	CrawlerTime string                   `json:"crawler_time"`
+	Content     []map[string]interface{} `json:"content"`
+	// New 与上一次结果相比新上榜条目的下标
+	New []int `json:"new"`
+	// Keys 与 Content 一一对应的条目标识, 见 cralwer.ItemKey
+	Keys []string `json:"keys"`
+}
+
+// Hub 记录每个热榜最近一次的结果, 有更新时广播给全部订阅者
+type Hub struct {
+	mu      sync.Mutex
+	clients map[chan Event]struct{}
+	last    map[string]cralwer.Result
+	done    chan struct{}
+	once    sync.Once
+}
+
+// NewHub 创建 Hub, 以存储中当前的结果为基准, 之后的更新才会推送
+func NewHub() *Hub {
+	h := &Hub{clients: map[chan Event]struct{}{}, done: make(chan struct{})}
+	h.Check()
+	return h
+}
+
+// Run 定期检查存储, 集群模式下其它实例写入的结果也能推送, ctx 取消后返回
+func (h *Hub) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.Check()
+		}
+	}
+}
+
+// Check 读取存储中的最新结果, 抓取时间比已知结果新的热榜会推送给订阅者
+func (h *Hub) Check() {
+	results, err := cralwer.ReadResults()
+	if err != nil {
+		fmt.Println("live read results err:", err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	first := h.last == nil
+	if first {
+		h.last = map[string]cralwer.Result{}
+	}
+	for _, result := range results {
+		key := cralwer.ResultKey(result)
+		old, ok := h.last[key]
+		if ok && result.CrawlerTime <= old.CrawlerTime {
+			continue
+		}
+		h.last[key] = result
+		if first || result.CrawlerTime == "" {
+			continue
+		}
+		event := Event{
+			Source:      result.Source,
+			HotName:     result.HotName,
+			CrawlerTime: result.CrawlerTime,
+			Content:     result.Content,
+			New:         newItems(old.Content, result.Content),
+			Keys:        itemKeys(result.Source, result.Content),
+		}
+		for client := range h.clients {
+			select {
+			case client <- event:
+			default:
+				// 浏览器处理不过来时丢弃, 下一次更新会带上完整的榜单
+			}
+		}
+	}
+}
+
+// newItems 返回 current 中不在 previous 里的条目下标, 标题按 cralwer.NormalizeTitle 匹配
+func newItems(previous, current []map[string]interface{}) []int {
+	seen := map[string]bool{}
+	for _, item := range previous {
+		seen[cralwer.NormalizeTitle(fmt.Sprint(item["title"]))] = true
+	}
+	indexes := []int{}
+	for index, item := range current {
+		if !seen[cralwer.NormalizeTitle(fmt.Sprint(item["title"]))] {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// itemKeys 返回每个条目的标识, 页面用它匹配已读记录和收藏
+func itemKeys(source string, content []map[string]interface{}) []string {
+	keys := make([]string, 0, len(content))
+	for _, item := range content {
+		keys = append(keys, cralwer.ItemKey(source, fmt.Sprint(item["title"])))
+	}
+	return keys
+}
+
+func (h *Hub) subscribe() chan Event {
+	client := make(chan Event, 16)
+	h.mu.Lock()
+	h.clients[client] = struct{}{}
+	h.mu.Unlock()
+	return client
+}
+
+func (h *Hub) unsubscribe(client chan Event) {
+	h.mu.Lock()
+	delete(h.clients, client)
+	h.mu.Unlock()
+}
+
+// Close 结束全部事件流, 用于服务退出时让长连接尽快返回
+func (h *Hub) Close() {
+	h.once.Do(func() {
+		close(h.done)
+	})
+}
+
+// ServeHTTP 事件流, GET /api/events, 每次更新发送一个 board 事件
+func (h *Hub) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		http.Error(writer, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	writer.Header().Set("Cache-Control", "no-cache")
+	// 关闭 nginx 的响应缓冲
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+	// 断线后浏览器 10 秒后重连
+	_, _ = fmt.Fprint(writer, "retry: 10000\n\n")
+	flusher.Flush()
+
+	client := h.subscribe()
+	defer h.unsubscribe(client)
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-h.done:
+			return
+		case <-keepAlive.C:
+			_, err := fmt.Fprint(writer, ": keep-alive\n\n")
+			if err != nil {
+				return
+			}
+		case event := <-client:
+			data, _ := json.Marshal(event)
+			_, err := fmt.Fprintf(writer, "event: board\ndata: %s\n\n", data)
+			if err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -1,80 +1,495 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
+	"goCrawlerHot/auth"
+	"goCrawlerHot/classify"
+	"goCrawlerHot/config"
 	"goCrawlerHot/cralwer"
-	"io"
+	"goCrawlerHot/digest"
+	"goCrawlerHot/export"
+	"goCrawlerHot/i18n"
+	"goCrawlerHot/live"
+	"goCrawlerHot/rank"
+	"goCrawlerHot/store"
+	"goCrawlerHot/trend"
+	"html/template"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"text/template"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
-type Result struct {
-	HotName     string                   `json:"hot_name"`
-	Content     []map[string]interface{} `json:"content"`
-	CrawlerTime string                   `json:"crawler_time"`
-}
-
 var baseDir string
 
-func init() {
-	baseDir, _ := os.Getwd()
-	fmt.Println(baseDir)
-}
+// liveCheckInterval 检查存储中是否有新结果的间隔, 用于推送其它实例抓取的结果
+const liveCheckInterval = 10 * time.Second
+
+// shutdownTimeout 退出时等待请求处理和当前一轮抓取完成的最长时间
+const shutdownTimeout = 30 * time.Second
+
+// overrideSyncInterval 集群模式下检查其它实例保存的数据源配置修改的间隔
+const overrideSyncInterval = 30 * time.Second
 
 func main() {
-	go cralwer.RunTicker()
-	http.Handle("/layui/", http.StripPrefix("/layui/", http.FileServer(http.Dir("./html/layui/"))))
-	http.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
-		temFilePath := filepath.Join(baseDir, "html", "index.html")
-		htmlByte, err := ioutil.ReadFile(temFilePath)
+	flag.Usage = usage
+	configPath := flag.String("config", "config.json", "配置文件路径")
+	flag.Parse()
+	args := flag.Args()
+	// 不带子命令时与之前一样启动 web 服务
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+	if args[0] != "validate-config" {
+		err := config.Load(*configPath)
 		if err != nil {
-			fmt.Println("read html failed, err:", err)
-			return
+			fmt.Fprintln(os.Stderr, "config.Load err:", err)
+			os.Exit(1)
 		}
-		// 自定义一个模板函数
-		addNum := func(arg int) (int, error) {
-			return arg + 1, nil
+		// 配置有误时直接退出, 不带着被跳过的规则或运行中才报错的配置启动
+		if errs := config.Validate(config.Get()); len(errs) > 0 {
+			for _, err := range errs {
+				fmt.Fprintln(os.Stderr, "config err:", err)
+			}
+			os.Exit(1)
 		}
-		// 采用链式操作在Parse之前调用Funcs添加自定义的kua函数
-		tmpl, err := template.New("hello").Funcs(template.FuncMap{"addNum": addNum}).Parse(string(htmlByte))
+		s, err := store.Open(config.Get().Storage)
 		if err != nil {
-			fmt.Println("create template failed, err:", err)
-			return
+			fmt.Fprintln(os.Stderr, "store.Open err:", err)
+			os.Exit(1)
 		}
+		cralwer.SetStore(s)
+		// 集群模式下管理页面的修改保存在共享存储中, 覆盖配置文件中的同名字段
+		cralwer.LoadOverrides()
+		classifier, err := classify.Open(config.Get().Classifier)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "classify.Open err:", err)
+			os.Exit(1)
+		}
+		cralwer.SetClassifier(classifier)
+	}
+	os.Exit(command(*configPath, args[1:]))
+}
+
+// serve 启动 web 服务并定时抓取
+func serve(_ string, args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "监听地址")
+	_ = flags.Parse(args)
+	workDir, _ := os.Getwd()
+	fmt.Println(workDir)
+	err := auth.Setup(config.Get())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "auth.Setup err:", err)
+		return 1
+	}
 
-		fileName := filepath.Join(baseDir, "result.json")
-		file, err := os.Open(fileName)
+	// 收到 Ctrl+C 或 SIGTERM 时开始优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	tickerDone := runScheduled(ctx)
+	// 本实例保存结果后立即推送, 其它实例写入的结果由定期检查发现
+	hub := live.NewHub()
+	cralwer.OnSave(func([]cralwer.Result) {
+		hub.Check()
+	})
+	go hub.Run(ctx, liveCheckInterval)
+	go cralwer.SyncOverrides(ctx, overrideSyncInterval)
+	http.Handle("/layui/", http.StripPrefix("/layui/", http.FileServer(http.Dir("./html/layui/"))))
+	http.HandleFunc("/", auth.Require(auth.Viewer, gzipHandler(func(writer http.ResponseWriter, request *http.Request) {
+		hotData, err := cralwer.ReadResults()
+		if err != nil {
+			fmt.Println("read results err:", err)
+		}
+		lang := i18n.Negotiate(writer, request)
+		p := readPrefs(request)
+		results, options := p.apply(hotData, lang)
+		renderPage(writer, lang, "index.html", indexPage{
+			Results:       results,
+			Layout:        p.Layout,
+			HideRead:      p.HideRead,
+			ShowSensitive: p.ShowSensitive,
+			Categories:    pageCategories(results),
+			Sources:       options,
+			User:          currentUser(request),
+		})
+	})))
+	http.HandleFunc("/lite", auth.Require(auth.Viewer, gzipHandler(liteHandler)))
+	http.HandleFunc("/manifest.webmanifest", staticFile("manifest.webmanifest", "application/manifest+json"))
+	http.HandleFunc("/icon.svg", staticFile("icon.svg", "image/svg+xml"))
+	http.HandleFunc("/sw.js", staticFile("sw.js", "application/javascript; charset=utf-8"))
+	http.HandleFunc("/rank", auth.Require(auth.Viewer, func(writer http.ResponseWriter, request *http.Request) {
+		board, err := rank.Build(request.URL.Query().Get("method"), 0)
 		if err != nil {
-			fmt.Println("Read file err, err =", err)
+			http.Error(writer, err.Error(), http.StatusBadRequest)
 			return
 		}
-		defer func(file *os.File) {
-			err := file.Close()
+		renderPage(writer, i18n.Negotiate(writer, request), "rank.html", board)
+	}))
+
+	// 只读接口需要 viewer, 会修改热榜数据的接口需要 editor
+	http.HandleFunc("/api/hot", auth.Require(auth.Viewer, gzipHandler(hotHandler)))
+	http.HandleFunc("/api/sources", auth.Require(auth.Viewer, sourcesHandler))
+	http.HandleFunc("/api/read", auth.Require(auth.Viewer, readHandler))
+	http.HandleFunc("/api/bookmarks", auth.Require(auth.Viewer, bookmarksHandler))
+	http.HandleFunc("/api/events", auth.Require(auth.Viewer, hub.ServeHTTP))
+	http.HandleFunc("/api/rank", auth.Require(auth.Viewer, rankHandler))
+	http.HandleFunc("/api/trend/item", auth.Require(auth.Viewer, itemTrendHandler))
+	http.HandleFunc("/api/trend/source", auth.Require(auth.Viewer, sourceTrendHandler))
+	http.HandleFunc("/api/refresh", auth.Require(auth.Editor, refreshHandler))
+	http.HandleFunc("/export", auth.Require(auth.Viewer, exportHandler))
+	// 数据源管理需要 admin
+	http.HandleFunc("/admin", auth.Require(auth.Admin, adminHandler))
+	http.HandleFunc("/api/admin/sources", auth.Require(auth.Admin, adminSourcesHandler))
+	http.HandleFunc("/api/admin/test", auth.Require(auth.Admin, adminTestHandler))
+	http.HandleFunc("/api/admin/failures", auth.Require(auth.Admin, adminFailuresHandler))
+	http.HandleFunc("/api/admin/filtered", auth.Require(auth.Admin, adminFilteredHandler))
+	http.HandleFunc("/auth/login", auth.LoginHandler)
+	http.HandleFunc("/auth/callback", auth.CallbackHandler)
+	http.HandleFunc("/auth/logout", auth.LogoutHandler)
+	http.HandleFunc("/auth/me", auth.MeHandler)
+
+	// addr：监听的地址
+	// handler：回调函数
+	server := &http.Server{Addr: *addr}
+	// 事件流是长连接, 退出时先结束它们, 否则 Shutdown 会一直等到超时
+	server.RegisterOnShutdown(hub.Close)
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			fmt.Println("http.ListenAndServe err:", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	fmt.Println("正在退出, 等待请求和抓取结束...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		fmt.Println("server.Shutdown err:", err)
+	}
+	select {
+	case <-tickerDone:
+		fmt.Println("已退出")
+	case <-shutdownCtx.Done():
+		fmt.Println("等待抓取超时, 强制退出")
+	}
+	return 0
+}
+
+// runScheduled 在后台运行定时抓取和摘要, 集群模式下只有 leader 运行, 所有实例都从共享存储提供页面和接口
+// ctx 取消后不再开始新的一轮, 当前一轮抓取完成并保存后关闭返回的 channel
+func runScheduled(ctx context.Context) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		cralwer.RunAsLeader(ctx, cralwer.RunTicker, digest.Run)
+		close(done)
+	}()
+	return done
+}
+
+// indexPage 首页模板的数据
+type indexPage struct {
+	Results []cralwer.Result
+	// Layout tabs 或 grid
+	Layout string
+	// HideRead 是否隐藏已读条目
+	HideRead bool
+	// ShowSensitive 是否显示标记为敏感的条目
+	ShowSensitive bool
+	// Categories 当前条目中出现的分类, 用于按分类筛选
+	Categories []string
+	// Sources 设置面板中按偏好排列的全部数据源
+	Sources []sourceOption
+	User    pageUser
+}
+
+// pageUser 页面右上角显示的当前用户
+type pageUser struct {
+	// Name 未登录时为空
+	Name string
+	Role string
+	// Session 通过 OIDC 登录, 可以退出
+	Session bool
+	// Login 配置了 OIDC 登录
+	Login bool
+}
+
+func currentUser(request *http.Request) pageUser {
+	user := pageUser{Login: auth.LoginEnabled()}
+	if p := auth.FromRequest(request); p != nil {
+		user.Name, user.Role, user.Session = p.Name, p.Role.String(), p.Method == "session"
+	}
+	return user
+}
+
+// renderPage 用 html 目录下的模板渲染页面, 文案、数据源名称和时间按 lang 显示,
+// 抓取的标题、链接和错误信息由 html/template 按所在上下文转义
+func renderPage(writer http.ResponseWriter, lang, name string, data interface{}) {
+	temFilePath := filepath.Join(baseDir, "html", name)
+	htmlByte, err := ioutil.ReadFile(temFilePath)
+	if err != nil {
+		fmt.Println("read html failed, err:", err)
+		return
+	}
+	// 自定义一个模板函数
+	addNum := func(arg int) (int, error) {
+		return arg + 1, nil
+	}
+	// 采用链式操作在Parse之前调用Funcs添加自定义的kua函数
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		"addNum": addNum,
+		"itemKey": func(source string, title interface{}) string {
+			return cralwer.ItemKey(source, fmt.Sprint(title))
+		},
+		"lang": func() string {
+			return lang
+		},
+		"t": func(key string, args ...interface{}) string {
+			return i18n.T(lang, key, args...)
+		},
+		// sourceName 按数据源 ID 取名称, 没有 source 字段的旧数据使用存储中的 hot_name
+		"sourceName": func(id, hotName string) string {
+			if !cralwer.IsSource(id) && hotName != "" {
+				return hotName
+			}
+			return cralwer.SourceName(id, lang)
+		},
+		// localTime 格式化抓取时间, 无法解析时原样返回
+		"localTime": func(value string) string {
+			t, err := time.ParseInLocation(cralwer.TimeLayout, value, time.Local)
 			if err != nil {
-				fmt.Println("file cloe err:", err)
+				return value
 			}
-		}(file)
-		str, _ := io.ReadAll(file)
-		var hotData []Result
-		err = json.Unmarshal(str, &hotData)
-		if err != nil {
-			fmt.Println("json Unmarshal err:", err)
+			return i18n.FormatTime(lang, t)
+		},
+		"formatTime": func(t time.Time) string {
+			return i18n.FormatTime(lang, t)
+		},
+		// messages 在 script 中由 html/template 编码为 JSON 对象
+		"messages": func() map[string]string {
+			return i18n.Messages(lang)
+		},
+		// categories 条目的分类, 以空格分隔, 写入行的 data-categories
+		"categories": func(item map[string]interface{}) string {
+			return strings.Join(cralwer.ItemCategories(item), " ")
+		},
+		"sensitive": cralwer.ItemSensitive,
+		// categoryName 分类的名称, 配置中新增的分类没有翻译时显示分类 ID
+		"categoryName": func(category string) string {
+			if name := i18n.T(lang, "cat."+category); name != "cat."+category {
+				return name
+			}
+			return category
+		},
+	}).Parse(string(htmlByte))
+	if err != nil {
+		fmt.Println("create template failed, err:", err)
+		return
+	}
+	// 利用给定数据渲染模板，并将结果写入w
+	err = tmpl.Execute(writer, data)
+	if err != nil {
+		fmt.Println("temp.Execute err:", err)
+	}
+}
+
+// writeJSON 以 JSON 格式返回数据
+func writeJSON(writer http.ResponseWriter, status int, data interface{}) {
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.WriteHeader(status)
+	err := json.NewEncoder(writer).Encode(data)
+	if err != nil {
+		fmt.Println("json Encode err:", err)
+	}
+}
+
+// hotHandler 当前热榜, GET /api/hot[?source=CrawlerZhiHu][&sort=heat][&limit=30][&category=tech][&sensitive=hide],
+// sort=heat 时按热度从高到低排列, limit 限制每个热榜返回的条目数, category 只返回该分类的条目, sensitive=hide 去掉敏感内容
+func hotHandler(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	limit := 0
+	if query.Get("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
+		if err != nil || limit < 0 {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+	}
+	results, err := cralwer.ReadResults()
+	if err != nil {
+		writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	results = export.Filter(results, query.Get("source"))
+	for i := range results {
+		results[i].Content = cralwer.FilterCategory(results[i].Content, query.Get("category"), query.Get("sensitive") == "hide")
+	}
+	if query.Get("sort") == "heat" {
+		for i := range results {
+			results[i].Content = cralwer.SortByHeat(results[i].Content)
+		}
+	}
+	for i := range results {
+		if limit > 0 && len(results[i].Content) > limit {
+			results[i].Content = results[i].Content[:limit]
 		}
-		// 利用给定数据渲染模板，并将结果写入w
-		err = tmpl.Execute(writer, hotData)
+	}
+	writeJSON(writer, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// sourceItem 数据源接口返回的一个数据源
+type sourceItem struct {
+	cralwer.SourceInfo
+	// Name 请求语言下的名称
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// sourcesHandler 数据源列表, GET /api/sources[?lang=en], 语言未指定时按 cookie 和 Accept-Language 协商
+func sourcesHandler(writer http.ResponseWriter, request *http.Request) {
+	lang := i18n.Negotiate(writer, request)
+	var items []sourceItem
+	for _, info := range cralwer.SourceInfos() {
+		items = append(items, sourceItem{SourceInfo: info, Name: cralwer.SourceName(info.ID, lang), Enabled: cralwer.Enabled(info.ID)})
+	}
+	writeJSON(writer, http.StatusOK, map[string]interface{}{"lang": lang, "sources": items})
+}
+
+// rankHandler 全平台热度榜, GET /api/rank[?method=rank|zscore][&limit=50]
+func rankHandler(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	limit := 0
+	if query.Get("limit") != "" {
+		var err error
+		limit, err = strconv.Atoi(query.Get("limit"))
 		if err != nil {
-			fmt.Println("temp.Execute err:", err)
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
 		}
-	})
+	}
+	board, err := rank.Build(query.Get("method"), limit)
+	if err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(writer, http.StatusOK, board)
+}
 
-	// addr：监听的地址
-	// handler：回调函数
-	err := http.ListenAndServe(":8080", nil)
+// maxTrendHours 趋势接口最多回看的小时数
+const maxTrendHours = 24 * 31
+
+// trendRange 解析趋势接口的 hours 参数, 默认最近 24 小时
+func trendRange(query url.Values) (time.Time, time.Time, error) {
+	hours := 24
+	if query.Get("hours") != "" {
+		var err error
+		hours, err = strconv.Atoi(query.Get("hours"))
+		if err != nil || hours <= 0 || hours > maxTrendHours {
+			return time.Time{}, time.Time{}, fmt.Errorf("hours must be between 1 and %d", maxTrendHours)
+		}
+	}
+	now := time.Now()
+	return now.Add(-time.Duration(hours) * time.Hour), now, nil
+}
+
+// itemTrendHandler 话题的排名和热度变化, GET /api/trend/item?source=CrawlerZhiHu&title=...[&hours=24]
+func itemTrendHandler(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	from, to, err := trendRange(query)
+	if err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	result, err := trend.Item(query.Get("source"), query.Get("title"), from, to)
+	if err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(writer, http.StatusOK, result)
+}
+
+// sourceTrendHandler 热榜每小时新上榜的条目数, GET /api/trend/source?source=CrawlerZhiHu[&hours=24]
+func sourceTrendHandler(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	from, to, err := trendRange(query)
+	if err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	result, err := trend.Source(query.Get("source"), from, to)
+	if err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(writer, http.StatusOK, result)
+}
+
+// refreshHandler 立即抓取, POST /api/refresh[?source=CrawlerZhiHu], 返回各数据源的抓取结果
+func refreshHandler(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writer.Header().Set("Allow", http.MethodPost)
+		writeJSON(writer, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+	reports, err := cralwer.Refresh(request.URL.Query().Get("source"))
+	if err != nil {
+		writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(writer, http.StatusOK, map[string]interface{}{"reports": reports})
+}
+
+// exportHandler 下载热榜, GET /export?format=csv|xlsx|json|md[&source=][&from=][&to=]
+func exportHandler(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+	format := query.Get("format")
+	contentType := export.ContentType(format)
+	if contentType == "" {
+		http.Error(writer, "unknown format", http.StatusBadRequest)
+		return
+	}
+	results, err := export.Load(query.Get("source"), query.Get("from"), query.Get("to"))
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := "hot"
+	if query.Get("source") != "" {
+		name += "-" + query.Get("source")
+	}
+	name += "-" + time.Now().Format("20060102150405") + "." + format
+	// 先写入内存, 出错时还能返回错误状态码
+	var buf bytes.Buffer
+	err = export.Write(&buf, format, results)
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	_, err = writer.Write(buf.Bytes())
 	if err != nil {
-		fmt.Println("http.ListenAndServe err:", err)
+		fmt.Println("export write err:", err)
 	}
 }
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"goCrawlerHot/auth"
+	"goCrawlerHot/cralwer"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// userCookie 标识匿名用户的 cookie, 已读记录和收藏按该标识保存在服务端
+const userCookie = "hot_uid"
+
+// maxMarkKeys 一次请求最多标记的条目数
+const maxMarkKeys = 500
+
+// maxBookmarks 每个用户最多的收藏数
+const maxBookmarks = 500
+
+// maxBookmarkTitle, maxBookmarkHref 收藏的标题和链接的最大字节数
+const (
+	maxBookmarkTitle = 500
+	maxBookmarkHref  = 2048
+)
+
+// userID 返回请求的用户标识, 已登录时使用认证方式和稳定的用户标识(如 oidc:<sub>), 否则使用 cookie, 没有或无效时生成新的标识写入 cookie
+func userID(writer http.ResponseWriter, request *http.Request) string {
+	if p := auth.FromRequest(request); p != nil {
+		return "user:" + p.ID
+	}
+	if cookie, err := request.Cookie(userCookie); err == nil && validUserID(cookie.Value) {
+		return cookie.Value
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+	http.SetCookie(writer, &http.Cookie{
+		Name:     userCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   2 * 365 * 24 * 3600,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+// checkBookmark 检查收藏的数据源、标题和链接, 链接只允许 http 和 https, 避免在页面中执行 javascript: 链接
+func checkBookmark(item markItem) error {
+	if !cralwer.IsSource(item.Source) {
+		return errors.New("unknown source")
+	}
+	if strings.TrimSpace(item.Title) == "" || len(item.Title) > maxBookmarkTitle {
+		return errors.New("invalid title")
+	}
+	if item.Href == "" {
+		return nil
+	}
+	u, err := url.Parse(item.Href)
+	if err != nil || len(item.Href) > maxBookmarkHref || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("href must be an http or https url")
+	}
+	return nil
+}
+
+func validUserID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+// markItem 按数据源和标题指定的条目
+type markItem struct {
+	Source string `json:"source"`
+	Title  string `json:"title"`
+	Href   string `json:"href,omitempty"`
+}
+
+// markRequest 已读接口的请求体, 条目可以用 keys 直接指定, 也可以用 items 按数据源和标题指定
+type markRequest struct {
+	Keys  []string   `json:"keys"`
+	Items []markItem `json:"items"`
+}
+
+// keys 合并 Keys 和 Items 对应的条目标识
+func (r markRequest) keys() []string {
+	keys := append([]string(nil), r.Keys...)
+	for _, item := range r.Items {
+		keys = append(keys, cralwer.ItemKey(item.Source, item.Title))
+	}
+	return keys
+}
+
+// readHandler 已读记录, GET /api/read 返回最近 7 天的已读条目,
+// POST 标记为已读, DELETE 取消已读, 请求体为 {"keys": [...], "items": [{"source": "...", "title": "..."}]}
+func readHandler(writer http.ResponseWriter, request *http.Request) {
+	marks, err := cralwer.Marks()
+	if err != nil {
+		writeJSON(writer, http.StatusNotImplemented, map[string]string{"error": err.Error()})
+		return
+	}
+	user := userID(writer, request)
+	switch request.Method {
+	case http.MethodGet:
+		keys, err := marks.ReadKeys(user, time.Now().Add(-cralwer.ReadRetention))
+		if err != nil {
+			writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(writer, http.StatusOK, map[string]interface{}{"keys": keys})
+	case http.MethodPost, http.MethodDelete:
+		var body markRequest
+		err = json.NewDecoder(http.MaxBytesReader(writer, request.Body, 1<<20)).Decode(&body)
+		if err != nil {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid body"})
+			return
+		}
+		keys := body.keys()
+		if len(keys) > maxMarkKeys {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "too many items"})
+			return
+		}
+		if request.Method == http.MethodPost {
+			err = marks.MarkRead(user, keys, time.Now())
+		} else {
+			err = marks.MarkUnread(user, keys)
+		}
+		if errors.Is(err, cralwer.ErrTooManyUsers) {
+			writeJSON(writer, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(writer, http.StatusOK, map[string]interface{}{"keys": keys})
+	default:
+		writer.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSON(writer, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
+
+// hasBookmark 是否已收藏 key, 已收藏的条目达到上限时仍可更新
+func hasBookmark(bookmarks []cralwer.Bookmark, key string) bool {
+	for _, bookmark := range bookmarks {
+		if bookmark.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// bookmarksHandler 收藏, GET /api/bookmarks 返回收藏列表,
+// POST 添加收藏, 请求体为 {"source": "...", "title": "...", "href": "..."}, 每个用户最多 maxBookmarks 条, 链接只允许 http 和 https,
+// DELETE /api/bookmarks?key=... 取消收藏
+func bookmarksHandler(writer http.ResponseWriter, request *http.Request) {
+	marks, err := cralwer.Marks()
+	if err != nil {
+		writeJSON(writer, http.StatusNotImplemented, map[string]string{"error": err.Error()})
+		return
+	}
+	user := userID(writer, request)
+	switch request.Method {
+	case http.MethodGet:
+		bookmarks, err := marks.Bookmarks(user)
+		if err != nil {
+			writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(writer, http.StatusOK, map[string]interface{}{"bookmarks": bookmarks})
+	case http.MethodPost:
+		var item markItem
+		err = json.NewDecoder(http.MaxBytesReader(writer, request.Body, 1<<20)).Decode(&item)
+		if err != nil {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "invalid body"})
+			return
+		}
+		if err := checkBookmark(item); err != nil {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		bookmarks, err := marks.Bookmarks(user)
+		if err != nil {
+			writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		key := cralwer.ItemKey(item.Source, item.Title)
+		if len(bookmarks) >= maxBookmarks && !hasBookmark(bookmarks, key) {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "too many bookmarks"})
+			return
+		}
+		bookmark := cralwer.Bookmark{
+			Key:       key,
+			Source:    item.Source,
+			Title:     item.Title,
+			Href:      item.Href,
+			CreatedAt: time.Now().Format(cralwer.TimeLayout),
+		}
+		err = marks.SaveBookmark(user, bookmark)
+		if errors.Is(err, cralwer.ErrTooManyUsers) {
+			writeJSON(writer, http.StatusServiceUnavailable, map[string]string{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(writer, http.StatusOK, bookmark)
+	case http.MethodDelete:
+		key := request.URL.Query().Get("key")
+		if key == "" {
+			writeJSON(writer, http.StatusBadRequest, map[string]string{"error": "key is required"})
+			return
+		}
+		err = marks.DeleteBookmark(user, key)
+		if err != nil {
+			writeJSON(writer, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(writer, http.StatusOK, map[string]string{"key": key})
+	default:
+		writer.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSON(writer, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+	}
+}
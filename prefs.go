@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"goCrawlerHot/classify"
+	"goCrawlerHot/config"
+	"goCrawlerHot/cralwer"
+	"net/http"
+	"net/url"
+)
+
+// prefsCookie 保存页面偏好的 cookie, 值为 URL 编码的 JSON, 由页面上的设置写入
+const prefsCookie = "hot_prefs"
+
+// prefs 用户的页面偏好
+type prefs struct {
+	// Order 数据源顺序, 未列出的数据源按默认顺序排在后面
+	Order []string `json:"order,omitempty"`
+	// Hidden 不显示的数据源
+	Hidden []string `json:"hidden,omitempty"`
+	// Layout tabs 或 grid, 为空时使用配置的默认布局
+	Layout string `json:"layout,omitempty"`
+	// HideRead 隐藏已读的条目
+	HideRead bool `json:"hide_read,omitempty"`
+	// ShowSensitive 显示被分类器标记为敏感的条目, 默认隐藏
+	ShowSensitive bool `json:"show_sensitive,omitempty"`
+}
+
+// sourceOption 设置面板中的一个数据源
+type sourceOption struct {
+	ID     string
+	Name   string
+	Hidden bool
+}
+
+// readPrefs 读取请求中的偏好, cookie 不存在或无法解析时使用默认值
+func readPrefs(request *http.Request) prefs {
+	var p prefs
+	if cookie, err := request.Cookie(prefsCookie); err == nil {
+		if value, err := url.QueryUnescape(cookie.Value); err == nil {
+			_ = json.Unmarshal([]byte(value), &p)
+		}
+	}
+	if p.Layout != "tabs" && p.Layout != "grid" {
+		p.Layout = config.Get().Dashboard.Layout
+	}
+	return p
+}
+
+// order 返回按偏好排列的全部数据源
+func (p prefs) order() []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, name := range append(append([]string(nil), p.Order...), cralwer.DefaultOrder()...) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// apply 按偏好排列结果并去掉隐藏的数据源, 返回结果和设置面板使用的数据源列表, 数据源名称使用 lang
+func (p prefs) apply(results []cralwer.Result, lang string) ([]cralwer.Result, []sourceOption) {
+	hidden := map[string]bool{}
+	for _, name := range p.Hidden {
+		hidden[name] = true
+	}
+	bySource := map[string]cralwer.Result{}
+	for _, result := range results {
+		bySource[cralwer.ResultKey(result)] = result
+	}
+	var visible []cralwer.Result
+	var options []sourceOption
+	for _, name := range p.order() {
+		result, ok := bySource[name]
+		if !ok && !cralwer.IsSource(name) {
+			// cookie 中已经不存在的数据源
+			continue
+		}
+		option := sourceOption{ID: name, Name: cralwer.SourceName(name, lang), Hidden: hidden[name]}
+		options = append(options, option)
+		if ok && !hidden[name] {
+			visible = append(visible, result)
+		}
+		delete(bySource, name)
+	}
+	// 旧数据中没有 source 字段的结果保持原顺序放在最后
+	for _, result := range results {
+		if _, ok := bySource[cralwer.ResultKey(result)]; ok {
+			visible = append(visible, result)
+		}
+	}
+	return visible, options
+}
+
+// pageCategories 返回结果中出现的分类, 按内置分类的顺序排列
+func pageCategories(results []cralwer.Result) []string {
+	seen := map[string]bool{}
+	var categories []string
+	for _, result := range results {
+		for _, item := range result.Content {
+			for _, category := range cralwer.ItemCategories(item) {
+				if !seen[category] {
+					seen[category] = true
+					categories = append(categories, category)
+				}
+			}
+		}
+	}
+	return classify.Sort(categories)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"goCrawlerHot/cralwer"
+	"goCrawlerHot/i18n"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// staticFile 返回 html 目录下单个文件的处理函数, 用于 manifest、图标和 service worker 这类必须放在根路径的文件
+func staticFile(name, contentType string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Set("Content-Type", contentType)
+		// service worker 更新后浏览器要能尽快取到新版本
+		writer.Header().Set("Cache-Control", "no-cache")
+		http.ServeFile(writer, request, filepath.Join(baseDir, "html", name))
+	}
+}
+
+// litePage 精简版页面的数据, 条目由页面脚本从 /api/hot 获取
+type litePage struct {
+	// Sources 按偏好排列的未隐藏数据源
+	Sources []sourceOption
+	// ShowSensitive 是否显示标记为敏感的条目
+	ShowSensitive bool
+}
+
+// liteHandler 精简版页面, 不加载 layui, 每次只请求一个热榜的 JSON, 适合手机和较慢的网络
+func liteHandler(writer http.ResponseWriter, request *http.Request) {
+	results, err := cralwer.ReadResults()
+	if err != nil {
+		fmt.Println("read results err:", err)
+	}
+	lang := i18n.Negotiate(writer, request)
+	p := readPrefs(request)
+	_, options := p.apply(results, lang)
+	page := litePage{ShowSensitive: p.ShowSensitive}
+	for _, option := range options {
+		if !option.Hidden {
+			page.Sources = append(page.Sources, option)
+		}
+	}
+	renderPage(writer, lang, "lite.html", page)
+}
+
+// gzipResponseWriter 把响应体写入 gzip
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w gzipResponseWriter) Write(b []byte) (int, error) {
+	// 未设置类型时按压缩前的内容判断, 否则标准库会按压缩后的内容判断
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", http.DetectContentType(b))
+	}
+	return w.gz.Write(b)
+}
+
+// gzipHandler 客户端支持时压缩响应, 不能用于事件流这类需要 Flush 的处理函数
+func gzipHandler(next http.HandlerFunc) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		writer.Header().Add("Vary", "Accept-Encoding")
+		if !strings.Contains(request.Header.Get("Accept-Encoding"), "gzip") {
+			next(writer, request)
+			return
+		}
+		writer.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(writer)
+		defer gz.Close()
+		next(gzipResponseWriter{ResponseWriter: writer, gz: gz}, request)
+	}
+}
@@ -0,0 +1,241 @@
+// Package rank 把各热榜的条目归一化为可比较的分数, 生成全平台热度榜
+package rank
+
+import (
+	"fmt"
+	"goCrawlerHot/config"
+	"goCrawlerHot/cralwer"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 归一化方式
+const (
+	// ByRank 按榜内排名线性换算, 榜首为 1, 末位接近 0
+	ByRank = "rank"
+	// ByZScore 按热度取对数后的标准分换算为正态分布的百分位, 没有热度的榜按排名计算
+	ByZScore = "zscore"
+)
+
+// firstSeenWindow 计算话题首次上榜时间时回看的历史长度
+const firstSeenWindow = 24 * time.Hour
+
+// Appearance 话题在某个热榜上的位置
+type Appearance struct {
+	Source    string  `json:"source"`
+	HotName   string  `json:"hot_name"`
+	Rank      int     `json:"rank"`
+	Heat      float64 `json:"heat,omitempty"`
+	HeatLabel string  `json:"heat_label,omitempty"`
+	// Score 在该热榜上加权和衰减后的分数
+	Score float64 `json:"score"`
+}
+
+// Entry 全平台榜的一个话题, 同时出现在多个热榜时分数相加
+type Entry struct {
+	Title     string       `json:"title"`
+	Href      string       `json:"href"`
+	Score     float64      `json:"score"`
+	FirstSeen time.Time    `json:"first_seen"`
+	Sources   []Appearance `json:"sources"`
+}
+
+// Board 全平台热度榜
+type Board struct {
+	Method      string    `json:"method"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Entries     []Entry   `json:"entries"`
+}
+
+// Build 按当前配置从最新结果生成全平台榜, method 为空时使用配置的方式, limit 不大于 0 时使用配置的条数
+func Build(method string, limit int) (Board, error) {
+	cfg := config.Get().Ranking
+	if method == "" {
+		method = cfg.Method
+	}
+	if method != ByRank && method != ByZScore {
+		return Board{}, fmt.Errorf("unknown method %q, use %s or %s", method, ByRank, ByZScore)
+	}
+	if limit <= 0 {
+		limit = cfg.TopN
+	}
+	results, err := cralwer.ReadResults()
+	if err != nil {
+		return Board{}, err
+	}
+	now := time.Now()
+	seen, err := firstSeen(results, now)
+	if err != nil {
+		fmt.Println("rank firstSeen err:", err)
+	}
+	board := Board{Method: method, GeneratedAt: now, Entries: build(results, method, cfg, seen, now)}
+	if limit > 0 && len(board.Entries) > limit {
+		board.Entries = board.Entries[:limit]
+	}
+	return board, nil
+}
+
+// topicKey 同一热榜内话题的键
+func topicKey(source, title string) string {
+	return source + "\x00" + cralwer.NormalizeTitle(title)
+}
+
+func build(results []cralwer.Result, method string, cfg config.Ranking, seen map[string]time.Time, now time.Time) []Entry {
+	entries := map[string]*Entry{}
+	var order []string
+	for _, result := range results {
+		crawlerTime, err := time.ParseInLocation(cralwer.TimeLayout, result.CrawlerTime, time.Local)
+		if err != nil || len(result.Content) == 0 {
+			continue
+		}
+		weight, ok := cfg.Weights[result.Source]
+		if !ok {
+			weight = 1
+		}
+		scores := normalize(result.Content, method)
+		for index, item := range result.Content {
+			title := cralwer.ItemText(item, "title")
+			norm := cralwer.NormalizeTitle(title)
+			if norm == "" {
+				continue
+			}
+			first, ok := seen[topicKey(cralwer.ResultKey(result), title)]
+			if !ok || first.After(crawlerTime) {
+				first = crawlerTime
+			}
+			appearance := Appearance{
+				Source:    result.Source,
+				HotName:   result.HotName,
+				Rank:      index + 1,
+				HeatLabel: cralwer.ItemText(item, "heat_label"),
+				Score:     weight * scores[index] * decay(now.Sub(first), cfg.HalfLife),
+			}
+			appearance.Heat, _ = cralwer.HeatOf(item)
+
+			entry, ok := entries[norm]
+			if !ok {
+				entry = &Entry{Title: title, Href: cralwer.ItemText(item, "href"), FirstSeen: first}
+				entries[norm] = entry
+				order = append(order, norm)
+			}
+			// 同一个热榜重复出现的话题只计一次
+			duplicate := false
+			for _, existing := range entry.Sources {
+				if existing.Source == appearance.Source && existing.HotName == appearance.HotName {
+					duplicate = true
+				}
+			}
+			if duplicate {
+				continue
+			}
+			entry.Sources = append(entry.Sources, appearance)
+			entry.Score += appearance.Score
+			if first.Before(entry.FirstSeen) {
+				entry.FirstSeen = first
+			}
+		}
+	}
+	list := make([]Entry, 0, len(order))
+	for _, norm := range order {
+		list = append(list, *entries[norm])
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Score > list[j].Score
+	})
+	return list
+}
+
+// normalize 返回榜内每个条目归一化到 (0, 1] 的分数
+func normalize(content []map[string]interface{}, method string) []float64 {
+	n := len(content)
+	scores := make([]float64, n)
+	signals := make([]float64, n)
+	withHeat := method == ByZScore
+	for i, item := range content {
+		// 线性排名分, 榜首为 1
+		scores[i] = float64(n-i) / float64(n)
+		heat, ok := cralwer.HeatOf(item)
+		if !ok {
+			withHeat = false
+		}
+		// 热度通常是长尾分布, 取对数后再算标准分
+		signals[i] = math.Log1p(math.Max(heat, 0))
+	}
+	if method != ByZScore || n < 2 {
+		return scores
+	}
+	// 榜内有条目缺少热度时改用排名作为信号, 使不同热榜的分数仍然可比
+	if !withHeat {
+		for i := range signals {
+			signals[i] = float64(n - i)
+		}
+	}
+	var mean, variance float64
+	for _, v := range signals {
+		mean += v
+	}
+	mean /= float64(n)
+	for _, v := range signals {
+		variance += (v - mean) * (v - mean)
+	}
+	std := math.Sqrt(variance / float64(n))
+	if std == 0 {
+		return scores
+	}
+	for i, v := range signals {
+		// 标准分换算为正态分布的百分位
+		scores[i] = 0.5 * math.Erfc(-(v-mean)/std/math.Sqrt2)
+	}
+	return scores
+}
+
+// decay 按半衰期计算时间衰减系数, halfLife 为分钟数, 0 表示不衰减
+func decay(age time.Duration, halfLife int) float64 {
+	if halfLife <= 0 || age <= 0 {
+		return 1
+	}
+	return math.Pow(0.5, age.Minutes()/float64(halfLife))
+}
+
+// seenCache 首次上榜时间只在有新的抓取结果后重新计算
+var seenCache struct {
+	sync.Mutex
+	latest string
+	seen   map[string]time.Time
+}
+
+// firstSeen 从最近一天的历史中找出每个热榜上每个话题首次出现的时间
+func firstSeen(results []cralwer.Result, now time.Time) (map[string]time.Time, error) {
+	var latest string
+	for _, result := range results {
+		if result.CrawlerTime > latest {
+			latest = result.CrawlerTime
+		}
+	}
+	seenCache.Lock()
+	defer seenCache.Unlock()
+	if seenCache.seen != nil && seenCache.latest == latest {
+		return seenCache.seen, nil
+	}
+	history, err := cralwer.ReadHistory(now.Add(-firstSeenWindow), now, "")
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]time.Time{}
+	for _, result := range history {
+		crawlerTime, err := time.ParseInLocation(cralwer.TimeLayout, result.CrawlerTime, time.Local)
+		if err != nil {
+			continue
+		}
+		for _, item := range result.Content {
+			key := topicKey(cralwer.ResultKey(result), cralwer.ItemText(item, "title"))
+			if first, ok := seen[key]; !ok || crawlerTime.Before(first) {
+				seen[key] = crawlerTime
+			}
+		}
+	}
+	seenCache.latest, seenCache.seen = latest, seen
+	return seen, nil
+}
@@ -0,0 +1,302 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"goCrawlerHot/config"
+	"goCrawlerHot/cralwer"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// saveLatest 只在已有结果不比新结果新时写入, 避免多个实例的写入互相覆盖
+var saveLatest = redis.NewScript(`
+local old = redis.call('HGET', KEYS[1], ARGV[1])
+if old then
+	local ok, decoded = pcall(cjson.decode, old)
+	if ok and decoded.crawler_time and decoded.crawler_time > ARGV[2] then
+		return 0
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[3])
+return 1
+`)
+
+// acquireLease 租约不存在时写入, 由自己持有时续期
+var acquireLease = redis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if not holder then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+if holder == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// releaseLease 只删除自己持有的租约
+var releaseLease = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// redisStore 最新结果保存在 <prefix>latest 哈希中, 历史保存在以抓取时间为分数的 <prefix>history 有序集合中
+type redisStore struct {
+	client *redis.Client
+	prefix string
+	// usersMu 保护 pruned, pruned 为上一次清理 <prefix>mark_users 的时间
+	usersMu sync.Mutex
+	pruned  time.Time
+}
+
+func openRedis(dsn, prefix string) (*redisStore, error) {
+	opt, err := redis.ParseURL(dsn)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opt)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = client.Ping(ctx).Err()
+	if err != nil {
+		_ = client.Close()
+		return nil, err
+	}
+	return &redisStore{client: client, prefix: prefix}, nil
+}
+
+func (s *redisStore) Save(results []cralwer.Result) error {
+	ctx := context.Background()
+	for _, result := range results {
+		data, _ := json.Marshal(result)
+		err := saveLatest.Run(ctx, s.client, []string{s.prefix + "latest"}, cralwer.ResultKey(result), result.CrawlerTime, data).Err()
+		if err != nil {
+			return err
+		}
+		crawlerTime, err := time.ParseInLocation(cralwer.TimeLayout, result.CrawlerTime, time.Local)
+		// 抓取失败的结果没有抓取时间, 不写入历史
+		if err != nil {
+			continue
+		}
+		err = s.client.ZAdd(ctx, s.prefix+"history", &redis.Z{Score: float64(crawlerTime.Unix()), Member: data}).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *redisStore) Latest() ([]cralwer.Result, error) {
+	values, err := s.client.HVals(context.Background(), s.prefix+"latest").Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeResults(values, "")
+}
+
+func (s *redisStore) Range(from, to time.Time, source string) ([]cralwer.Result, error) {
+	values, err := s.client.ZRangeByScore(context.Background(), s.prefix+"history", &redis.ZRangeBy{
+		Min: strconv.FormatInt(from.Unix(), 10),
+		Max: strconv.FormatInt(to.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeResults(values, source)
+}
+
+// decodeResults 解析 JSON 结果, source 不为空时只保留该数据源
+func decodeResults(values []string, source string) ([]cralwer.Result, error) {
+	var results []cralwer.Result
+	for _, value := range values {
+		var result cralwer.Result
+		err := json.Unmarshal([]byte(value), &result)
+		if err != nil {
+			return results, err
+		}
+		if source != "" && result.Source != source && result.HotName != source {
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Acquire 租约保存在 <prefix>leader 中, 由 redis 负责过期, 不依赖各实例的时钟
+func (s *redisStore) Acquire(id string, ttl time.Duration) (bool, error) {
+	n, err := acquireLease.Run(context.Background(), s.client, []string{s.prefix + "leader"}, id, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (s *redisStore) Release(id string) error {
+	return releaseLease.Run(context.Background(), s.client, []string{s.prefix + "leader"}, id).Err()
+}
+
+// checkUser 新用户写入前检查用户数, 有已读记录或收藏的用户记录在 <prefix>mark_users 集合中,
+// 达到 cralwer.MaxMarkUsers 时先移除已读记录已过期且没有收藏的用户再检查
+func (s *redisStore) checkUser(ctx context.Context, user string) error {
+	usersKey := s.prefix + "mark_users"
+	exists, err := s.client.SIsMember(ctx, usersKey, user).Result()
+	if err != nil || exists {
+		return err
+	}
+	total, err := s.client.SCard(ctx, usersKey).Result()
+	if err != nil {
+		return err
+	}
+	if total >= cralwer.MaxMarkUsers {
+		total, err = s.pruneUsers(ctx)
+		if err != nil {
+			return err
+		}
+		if total >= cralwer.MaxMarkUsers {
+			return cralwer.ErrTooManyUsers
+		}
+	}
+	return s.client.SAdd(ctx, usersKey, user).Err()
+}
+
+// pruneUsers 从 <prefix>mark_users 中移除已经没有任何数据的用户, 返回剩余的用户数, 两次清理至少间隔 cralwer.MarkPruneInterval
+func (s *redisStore) pruneUsers(ctx context.Context) (int64, error) {
+	usersKey := s.prefix + "mark_users"
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	if time.Since(s.pruned) >= cralwer.MarkPruneInterval {
+		s.pruned = time.Now()
+		iter := s.client.SScan(ctx, usersKey, 0, "", 1000).Iterator()
+		for iter.Next(ctx) {
+			user := iter.Val()
+			n, err := s.client.Exists(ctx, s.prefix+"read:"+user, s.prefix+"bookmarks:"+user).Result()
+			if err != nil {
+				return 0, err
+			}
+			if n == 0 {
+				err = s.client.SRem(ctx, usersKey, user).Err()
+				if err != nil {
+					return 0, err
+				}
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return 0, err
+		}
+	}
+	return s.client.SCard(ctx, usersKey).Result()
+}
+
+// MarkRead 已读记录保存在以标记时间为分数的 <prefix>read:<用户> 有序集合中, 长时间不活跃的用户整个键过期,
+// 超过 cralwer.MaxReadMarks 时删除最早的记录
+func (s *redisStore) MarkRead(user string, keys []string, at time.Time) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ctx := context.Background()
+	err := s.checkUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	key := s.prefix + "read:" + user
+	members := make([]*redis.Z, 0, len(keys))
+	for _, item := range keys {
+		members = append(members, &redis.Z{Score: float64(at.Unix()), Member: item})
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, key, "-inf", "("+strconv.FormatInt(at.Add(-cralwer.ReadRetention).Unix(), 10))
+		pipe.ZAdd(ctx, key, members...)
+		pipe.ZRemRangeByRank(ctx, key, 0, -cralwer.MaxReadMarks-1)
+		pipe.Expire(ctx, key, cralwer.ReadRetention)
+		return nil
+	})
+	return err
+}
+
+func (s *redisStore) MarkUnread(user string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	members := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		members = append(members, key)
+	}
+	return s.client.ZRem(context.Background(), s.prefix+"read:"+user, members...).Err()
+}
+
+func (s *redisStore) ReadKeys(user string, since time.Time) ([]string, error) {
+	return s.client.ZRangeByScore(context.Background(), s.prefix+"read:"+user, &redis.ZRangeBy{
+		Min: strconv.FormatInt(since.Unix(), 10),
+		Max: "+inf",
+	}).Result()
+}
+
+// SaveBookmark 收藏保存在 <prefix>bookmarks:<用户> 哈希中, 已收藏时保留最初的收藏时间
+func (s *redisStore) SaveBookmark(user string, bookmark cralwer.Bookmark) error {
+	ctx := context.Background()
+	err := s.checkUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	key := s.prefix + "bookmarks:" + user
+	old, err := s.client.HGet(ctx, key, bookmark.Key).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	var existing cralwer.Bookmark
+	if err == nil && json.Unmarshal([]byte(old), &existing) == nil && existing.CreatedAt != "" {
+		bookmark.CreatedAt = existing.CreatedAt
+	}
+	data, _ := json.Marshal(bookmark)
+	return s.client.HSet(ctx, key, bookmark.Key, data).Err()
+}
+
+func (s *redisStore) DeleteBookmark(user, key string) error {
+	return s.client.HDel(context.Background(), s.prefix+"bookmarks:"+user, key).Err()
+}
+
+func (s *redisStore) Bookmarks(user string) ([]cralwer.Bookmark, error) {
+	values, err := s.client.HVals(context.Background(), s.prefix+"bookmarks:"+user).Result()
+	if err != nil {
+		return nil, err
+	}
+	bookmarks := []cralwer.Bookmark{}
+	for _, value := range values {
+		var bookmark cralwer.Bookmark
+		err = json.Unmarshal([]byte(value), &bookmark)
+		if err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	cralwer.SortBookmarks(bookmarks)
+	return bookmarks, nil
+}
+
+// SaveOverride 管理页面修改的数据源配置保存在 <prefix>sources 哈希中
+func (s *redisStore) SaveOverride(name string, override config.SourceOverride) error {
+	data, _ := json.Marshal(override)
+	return s.client.HSet(context.Background(), s.prefix+"sources", name, data).Err()
+}
+
+func (s *redisStore) Overrides() (map[string]config.SourceOverride, error) {
+	values, err := s.client.HGetAll(context.Background(), s.prefix+"sources").Result()
+	if err != nil {
+		return nil, err
+	}
+	overrides := map[string]config.SourceOverride{}
+	for name, value := range values {
+		var override config.SourceOverride
+		err = json.Unmarshal([]byte(value), &override)
+		if err != nil {
+			return nil, fmt.Errorf("%ssources %s: %w", s.prefix, name, err)
+		}
+		overrides[name] = override
+	}
+	return overrides, nil
+}
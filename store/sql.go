@@ -0,0 +1,369 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"goCrawlerHot/config"
+	"goCrawlerHot/cralwer"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// schema sqlite 和 postgres 通用的表结构, 抓取时间使用 cralwer.TimeLayout 格式的字符串, 按字符串比较即按时间比较
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS hot_latest (
+		source_key VARCHAR(64) PRIMARY KEY,
+		crawler_time VARCHAR(19) NOT NULL,
+		data TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS hot_history (
+		source_key VARCHAR(64) NOT NULL,
+		hot_name VARCHAR(64) NOT NULL,
+		crawler_time VARCHAR(19) NOT NULL,
+		data TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS hot_history_time ON hot_history (crawler_time)`,
+	`CREATE TABLE IF NOT EXISTS hot_lease (
+		name VARCHAR(64) PRIMARY KEY,
+		holder VARCHAR(255) NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS hot_read (
+		user_id VARCHAR(255) NOT NULL,
+		item_key VARCHAR(64) NOT NULL,
+		read_at BIGINT NOT NULL,
+		PRIMARY KEY (user_id, item_key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS hot_bookmark (
+		user_id VARCHAR(255) NOT NULL,
+		item_key VARCHAR(64) NOT NULL,
+		source VARCHAR(64) NOT NULL,
+		title TEXT NOT NULL,
+		href TEXT NOT NULL,
+		created_at VARCHAR(19) NOT NULL,
+		PRIMARY KEY (user_id, item_key)
+	)`,
+	`CREATE TABLE IF NOT EXISTS hot_source (
+		name VARCHAR(64) PRIMARY KEY,
+		data TEXT NOT NULL
+	)`,
+}
+
+// leaseName 抓取 leader 租约在 hot_lease 中的名称
+const leaseName = "crawler"
+
+// sqlStore 保存在 sqlite 或 postgres 中, hot_latest 每个数据源一行, hot_history 每次抓取一行
+type sqlStore struct {
+	db     *sql.DB
+	driver string
+	// usersMu 保护 pruned, pruned 为上一次清理过期已读记录的时间
+	usersMu sync.Mutex
+	pruned  time.Time
+}
+
+func openSQL(kind, dsn string) (*sqlStore, error) {
+	driver := "sqlite3"
+	if kind == "postgres" {
+		driver = "postgres"
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite3" {
+		// sqlite 同时只允许一个写入者, 用单连接避免 database is locked
+		db.SetMaxOpenConns(1)
+	}
+	for _, stmt := range schema {
+		_, err = db.Exec(stmt)
+		if err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("create %s schema: %w", kind, err)
+		}
+	}
+	return &sqlStore{db: db, driver: driver}, nil
+}
+
+// rebind 把 ? 占位符换成 postgres 使用的 $1, $2...
+func (s *sqlStore) rebind(query string) string {
+	if s.driver != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Save 在一个事务中更新最新结果并写入历史, 已有更新的结果时不覆盖
+func (s *sqlStore) Save(results []cralwer.Result) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	upsert := s.rebind(`INSERT INTO hot_latest (source_key, crawler_time, data) VALUES (?, ?, ?)
+		ON CONFLICT (source_key) DO UPDATE SET crawler_time = excluded.crawler_time, data = excluded.data
+		WHERE hot_latest.crawler_time <= excluded.crawler_time`)
+	insert := s.rebind(`INSERT INTO hot_history (source_key, hot_name, crawler_time, data) VALUES (?, ?, ?, ?)`)
+	for _, result := range results {
+		data, _ := json.Marshal(result)
+		_, err = tx.Exec(upsert, cralwer.ResultKey(result), result.CrawlerTime, string(data))
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		// 抓取失败的结果没有抓取时间, 不写入历史
+		if result.CrawlerTime == "" {
+			continue
+		}
+		_, err = tx.Exec(insert, cralwer.ResultKey(result), result.HotName, result.CrawlerTime, string(data))
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) Latest() ([]cralwer.Result, error) {
+	return s.query(`SELECT data FROM hot_latest`)
+}
+
+func (s *sqlStore) Range(from, to time.Time, source string) ([]cralwer.Result, error) {
+	query := `SELECT data FROM hot_history WHERE crawler_time >= ? AND crawler_time <= ?`
+	args := []interface{}{from.In(time.Local).Format(cralwer.TimeLayout), to.In(time.Local).Format(cralwer.TimeLayout)}
+	if source != "" {
+		query += ` AND (source_key = ? OR hot_name = ?)`
+		args = append(args, source, source)
+	}
+	return s.query(query+` ORDER BY crawler_time`, args...)
+}
+
+func (s *sqlStore) query(query string, args ...interface{}) ([]cralwer.Result, error) {
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		err := rows.Close()
+		if err != nil {
+			fmt.Println("rows close err:", err)
+		}
+	}(rows)
+	var results []cralwer.Result
+	for rows.Next() {
+		var data string
+		err = rows.Scan(&data)
+		if err != nil {
+			return results, err
+		}
+		var result cralwer.Result
+		err = json.Unmarshal([]byte(data), &result)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// Acquire 租约不存在、已过期或由自己持有时写入新的过期时间, 以是否写入成功判断是否持有租约
+// 过期时间使用各实例的本地时钟, 实例间的时钟误差需远小于租约时长
+func (s *sqlStore) Acquire(id string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := s.db.Exec(s.rebind(`INSERT INTO hot_lease (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE hot_lease.holder = excluded.holder OR hot_lease.expires_at < ?`),
+		leaseName, id, now.Add(ttl).Unix(), now.Unix())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *sqlStore) Release(id string) error {
+	_, err := s.db.Exec(s.rebind(`DELETE FROM hot_lease WHERE name = ? AND holder = ?`), leaseName, id)
+	return err
+}
+
+// countUsers 有已读记录或收藏的用户数
+func (s *sqlStore) countUsers(user string) (total int, exists bool, err error) {
+	err = s.db.QueryRow(s.rebind(`SELECT COUNT(*), COALESCE(SUM(CASE WHEN user_id = ? THEN 1 ELSE 0 END), 0)
+		FROM (SELECT user_id FROM hot_read UNION SELECT user_id FROM hot_bookmark) u`), user).Scan(&total, &exists)
+	return total, exists, err
+}
+
+// checkUser 新用户写入前检查用户数, 达到 cralwer.MaxMarkUsers 时先删除全部用户过期的已读记录再检查
+// 在事务之外调用, sqlite 只有一个连接
+func (s *sqlStore) checkUser(user string) error {
+	total, exists, err := s.countUsers(user)
+	if err != nil || exists || total < cralwer.MaxMarkUsers {
+		return err
+	}
+	s.usersMu.Lock()
+	defer s.usersMu.Unlock()
+	if time.Since(s.pruned) < cralwer.MarkPruneInterval {
+		return cralwer.ErrTooManyUsers
+	}
+	s.pruned = time.Now()
+	_, err = s.db.Exec(s.rebind(`DELETE FROM hot_read WHERE read_at < ?`), s.pruned.Add(-cralwer.ReadRetention).Unix())
+	if err != nil {
+		return err
+	}
+	total, _, err = s.countUsers(user)
+	if err == nil && total >= cralwer.MaxMarkUsers {
+		return cralwer.ErrTooManyUsers
+	}
+	return err
+}
+
+// MarkRead 在一个事务中写入已读记录, 删除该用户过期的记录, 超过 cralwer.MaxReadMarks 时删除最早的记录
+func (s *sqlStore) MarkRead(user string, keys []string, at time.Time) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	err := s.checkUser(user)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(s.rebind(`DELETE FROM hot_read WHERE user_id = ? AND read_at < ?`), user, at.Add(-cralwer.ReadRetention).Unix())
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	upsert := s.rebind(`INSERT INTO hot_read (user_id, item_key, read_at) VALUES (?, ?, ?)
+		ON CONFLICT (user_id, item_key) DO UPDATE SET read_at = excluded.read_at`)
+	for _, key := range keys {
+		_, err = tx.Exec(upsert, user, key, at.Unix())
+		if err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+	_, err = tx.Exec(s.rebind(`DELETE FROM hot_read WHERE user_id = ? AND item_key NOT IN (
+		SELECT item_key FROM hot_read WHERE user_id = ? ORDER BY read_at DESC, item_key LIMIT ?)`), user, user, cralwer.MaxReadMarks)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) MarkUnread(user string, keys []string) error {
+	for _, key := range keys {
+		_, err := s.db.Exec(s.rebind(`DELETE FROM hot_read WHERE user_id = ? AND item_key = ?`), user, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqlStore) ReadKeys(user string, since time.Time) ([]string, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT item_key FROM hot_read WHERE user_id = ? AND read_at >= ? ORDER BY item_key`), user, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		err = rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// SaveBookmark 已收藏时只更新标题和链接, 保留最初的收藏时间
+func (s *sqlStore) SaveBookmark(user string, bookmark cralwer.Bookmark) error {
+	err := s.checkUser(user)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(s.rebind(`INSERT INTO hot_bookmark (user_id, item_key, source, title, href, created_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, item_key) DO UPDATE SET title = excluded.title, href = excluded.href`),
+		user, bookmark.Key, bookmark.Source, bookmark.Title, bookmark.Href, bookmark.CreatedAt)
+	return err
+}
+
+func (s *sqlStore) DeleteBookmark(user, key string) error {
+	_, err := s.db.Exec(s.rebind(`DELETE FROM hot_bookmark WHERE user_id = ? AND item_key = ?`), user, key)
+	return err
+}
+
+func (s *sqlStore) Bookmarks(user string) ([]cralwer.Bookmark, error) {
+	rows, err := s.db.Query(s.rebind(`SELECT item_key, source, title, href, created_at FROM hot_bookmark
+		WHERE user_id = ? ORDER BY created_at DESC`), user)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	bookmarks := []cralwer.Bookmark{}
+	for rows.Next() {
+		var b cralwer.Bookmark
+		err = rows.Scan(&b.Key, &b.Source, &b.Title, &b.Href, &b.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, b)
+	}
+	return bookmarks, rows.Err()
+}
+
+// SaveOverride 管理页面修改的数据源配置保存在 hot_source 中, 每个数据源一行
+func (s *sqlStore) SaveOverride(name string, override config.SourceOverride) error {
+	data, _ := json.Marshal(override)
+	_, err := s.db.Exec(s.rebind(`INSERT INTO hot_source (name, data) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET data = excluded.data`), name, string(data))
+	return err
+}
+
+func (s *sqlStore) Overrides() (map[string]config.SourceOverride, error) {
+	rows, err := s.db.Query(`SELECT name, data FROM hot_source`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+	overrides := map[string]config.SourceOverride{}
+	for rows.Next() {
+		var name, data string
+		err = rows.Scan(&name, &data)
+		if err != nil {
+			return nil, err
+		}
+		var override config.SourceOverride
+		err = json.Unmarshal([]byte(data), &override)
+		if err != nil {
+			return nil, fmt.Errorf("hot_source %s: %w", name, err)
+		}
+		overrides[name] = override
+	}
+	return overrides, rows.Err()
+}
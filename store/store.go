@@ -0,0 +1,22 @@
+// Package store 按配置创建抓取结果的存储
+package store
+
+import (
+	"fmt"
+	"goCrawlerHot/config"
+	"goCrawlerHot/cralwer"
+)
+
+// Open 按配置创建存储, 数据库中的表在首次连接时创建
+func Open(cfg config.Storage) (cralwer.Store, error) {
+	switch cfg.Type {
+	case "", "file":
+		return cralwer.NewFileStore(cfg.DSN), nil
+	case "sqlite", "postgres":
+		return openSQL(cfg.Type, cfg.DSN)
+	case "redis":
+		return openRedis(cfg.DSN, cfg.Prefix)
+	default:
+		return nil, fmt.Errorf("unknown storage type %q", cfg.Type)
+	}
+}
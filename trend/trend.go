@@ -0,0 +1,104 @@
+// Package trend 从历史快照统计单个话题的排名变化和每个热榜的新增条目
+package trend
+
+import (
+	"fmt"
+	"goCrawlerHot/cralwer"
+	"strings"
+	"time"
+)
+
+// Point 话题在一次快照中的位置, 不在榜上时 Rank 为 0
+type Point struct {
+	Time time.Time `json:"time"`
+	Rank int       `json:"rank"`
+	Heat float64   `json:"heat,omitempty"`
+}
+
+// ItemTrend 单个话题的排名和热度变化
+type ItemTrend struct {
+	Source string  `json:"source"`
+	Title  string  `json:"title"`
+	Points []Point `json:"points"`
+}
+
+// Bucket 一个小时内的新增条目数
+type Bucket struct {
+	Hour time.Time `json:"hour"`
+	// New 与上一次快照相比新上榜的条目数
+	New int `json:"new"`
+	// Snapshots 这一小时内的快照数
+	Snapshots int `json:"snapshots"`
+}
+
+// SourceTimeline 单个热榜每小时的新增条目数
+type SourceTimeline struct {
+	Source  string   `json:"source"`
+	HotName string   `json:"hot_name"`
+	Buckets []Bucket `json:"buckets"`
+}
+
+// Item 统计 [from, to] 内话题在指定热榜上每次快照的排名, 标题按 cralwer.NormalizeTitle 匹配
+func Item(source, title string, from, to time.Time) (ItemTrend, error) {
+	if source == "" || strings.TrimSpace(title) == "" {
+		return ItemTrend{}, fmt.Errorf("source and title are required")
+	}
+	history, err := cralwer.ReadHistory(from, to, source)
+	if err != nil {
+		return ItemTrend{}, err
+	}
+	norm := cralwer.NormalizeTitle(title)
+	trend := ItemTrend{Source: source, Title: title, Points: []Point{}}
+	for _, result := range history {
+		crawlerTime, err := time.ParseInLocation(cralwer.TimeLayout, result.CrawlerTime, time.Local)
+		if err != nil {
+			continue
+		}
+		point := Point{Time: crawlerTime}
+		for index, item := range result.Content {
+			if cralwer.NormalizeTitle(fmt.Sprint(item["title"])) == norm {
+				point.Rank = index + 1
+				point.Heat, _ = cralwer.HeatOf(item)
+				break
+			}
+		}
+		trend.Points = append(trend.Points, point)
+	}
+	return trend, nil
+}
+
+// Source 统计 [from, to] 内热榜每小时新上榜的条目数, 第一次快照没有可比较的上一次快照, 不计入新增
+func Source(source string, from, to time.Time) (SourceTimeline, error) {
+	if source == "" {
+		return SourceTimeline{}, fmt.Errorf("source is required")
+	}
+	history, err := cralwer.ReadHistory(from, to, source)
+	if err != nil {
+		return SourceTimeline{}, err
+	}
+	timeline := SourceTimeline{Source: source, Buckets: []Bucket{}}
+	var previous map[string]bool
+	for _, result := range history {
+		crawlerTime, err := time.ParseInLocation(cralwer.TimeLayout, result.CrawlerTime, time.Local)
+		if err != nil {
+			continue
+		}
+		timeline.HotName = result.HotName
+		hour := time.Date(crawlerTime.Year(), crawlerTime.Month(), crawlerTime.Day(), crawlerTime.Hour(), 0, 0, 0, time.Local)
+		if n := len(timeline.Buckets); n == 0 || !timeline.Buckets[n-1].Hour.Equal(hour) {
+			timeline.Buckets = append(timeline.Buckets, Bucket{Hour: hour})
+		}
+		bucket := &timeline.Buckets[len(timeline.Buckets)-1]
+		bucket.Snapshots++
+		current := map[string]bool{}
+		for _, item := range result.Content {
+			norm := cralwer.NormalizeTitle(fmt.Sprint(item["title"]))
+			current[norm] = true
+			if previous != nil && !previous[norm] {
+				bucket.New++
+			}
+		}
+		previous = current
+	}
+	return timeline, nil
+}